@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gmail-forwarding/internal/processor"
+)
+
+// runBackfill 执行 backfill 子命令：按日期范围回溯处理历史邮件
+//
+//	main backfill -since 2024-01-01 [-before 2024-01-08] [-account 1] [-mailbox INBOX] [-dry-run]
+//
+// 转发邮件只写入发送队列，由运行中的服务进程发送。
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	accountID := fs.Uint("account", 0, "账户ID（只有一个账户时可以不指定）")
+	mailbox := fs.String("mailbox", "", "要回溯处理的邮箱/标签，默认为账户的第一个文件夹")
+	since := fs.String("since", "", "起始日期（含），格式 YYYY-MM-DD")
+	before := fs.String("before", "", "截止日期（不含），格式 YYYY-MM-DD")
+	dryRun := fs.Bool("dry-run", false, "只评估规则，不发送邮件")
+	fs.Parse(args)
+
+	opts := processor.BackfillOptions{Mailbox: *mailbox, DryRun: *dryRun}
+	var err error
+	if opts.Since, err = processor.ParseBackfillDate(*since); err != nil {
+		log.Fatalf("参数错误: %v", err)
+	}
+	if opts.Before, err = processor.ParseBackfillDate(*before); err != nil {
+		log.Fatalf("参数错误: %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("参数错误: %v", err)
+	}
+
+	account, err := processor.ResolveAccount(*accountID)
+	if err != nil {
+		log.Fatalf("邮箱账户无效: %v", err)
+	}
+	emailProcessor, err := processor.NewAccountProcessor(account)
+	if err != nil {
+		log.Fatalf("邮箱账户配置不完整: %v", err)
+	}
+
+	// 收到中断信号时在当前批次处理完后停止
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := emailProcessor.Backfill(ctx, opts, nil)
+	if err != nil {
+		log.Fatalf("回溯处理失败: %v", err)
+	}
+
+	// 试运行时输出命中规则的邮件明细
+	if opts.DryRun {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatalf("输出结果失败: %v", err)
+		}
+	}
+}
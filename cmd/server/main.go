@@ -1,20 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"gmail-forwarding/internal/api"
+	"gmail-forwarding/internal/api/handlers"
 	"gmail-forwarding/internal/config"
 	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/processor"
 	"gmail-forwarding/internal/scheduler"
 )
 
 func main() {
-	log.Println("启动 Gmail 邮件转发服务...")
-
 	// 1. 加载配置
 	config.Load()
 
@@ -23,9 +24,28 @@ func main() {
 		log.Fatalf("数据库初始化失败: %v", err)
 	}
 
-	// 3. 启动定时任务
+	// 子命令：回溯处理历史邮件
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+
+	log.Println("启动 Gmail 邮件转发服务...")
+
+	// 收到中断信号时取消 ctx，中断正在进行的邮件处理和发送
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 3. 启动发送队列和定时任务
+	outbox := processor.NewOutbox()
+	outbox.Start(ctx)
+
 	emailScheduler := scheduler.NewScheduler()
-	emailScheduler.Start()
+	emailScheduler.Start(ctx)
+
+	// 通过 API 修改邮箱账户后重新加载调度器
+	handlers.ReloadAccounts = emailScheduler.Reload
+	handlers.SendQuotas = outbox.Quotas
 
 	// 4. 设置路由并启动HTTP服务器
 	router := api.SetupRoutes()
@@ -40,14 +60,13 @@ func main() {
 	}()
 
 	// 等待中断信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
 
 	log.Println("收到退出信号，正在关闭服务...")
 
-	// 停止定时任务
+	// 停止定时任务和发送队列
 	emailScheduler.Stop()
+	outbox.Stop()
 
 	log.Println("服务已关闭")
 }
\ No newline at end of file
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+	"gmail-forwarding/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountResponse 邮箱账户响应结构
+type AccountResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// AccountRequest 邮箱账户请求结构
+//
+// 密码、OAuth2 客户端密钥和刷新令牌不会在响应中返回；更新时不传表示保持不变。
+// enabled 创建时不传默认为启用，更新时不传表示保持不变。
+type AccountRequest struct {
+	models.Account
+	Enabled            *bool   `json:"enabled"`
+	Password           *string `json:"password"`
+	OAuth2ClientSecret *string `json:"oauth2_client_secret"`
+	RefreshToken       *string `json:"refresh_token"`
+}
+
+// ReloadAccounts 账户变更后重新加载调度器，由启动流程设置
+var ReloadAccounts func()
+
+// reloadAccounts 通知调度器重新加载账户
+func reloadAccounts() {
+	if ReloadAccounts != nil {
+		go ReloadAccounts()
+	}
+}
+
+// GetAccounts 获取所有邮箱账户
+func GetAccounts(c *gin.Context) {
+	db := database.GetDB()
+	var accounts []models.Account
+
+	if err := db.Order("id").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "获取邮箱账户列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AccountResponse{
+		Success: true,
+		Message: "获取邮箱账户列表成功",
+		Data:    accounts,
+	})
+}
+
+// GetAccount 获取单个邮箱账户
+func GetAccount(c *gin.Context) {
+	account, ok := findAccountByParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, AccountResponse{
+		Success: true,
+		Message: "获取邮箱账户成功",
+		Data:    account,
+	})
+}
+
+// CreateAccount 创建邮箱账户
+func CreateAccount(c *gin.Context) {
+	var req AccountRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	account := req.Account
+	account.ID = 0
+
+	// 设置默认值
+	account.Enabled = req.Enabled == nil || *req.Enabled
+	if account.AuthMode == "" {
+		account.AuthMode = models.AuthModePassword
+	}
+	if account.Name == "" {
+		account.Name = account.Email
+	}
+	applyAccountSecrets(&account, &req)
+
+	if !validateAccountRequest(c, &account) {
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "创建邮箱账户失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if !saveAccountRefreshToken(c, &account, &req) {
+		return
+	}
+	reloadAccounts()
+
+	c.JSON(http.StatusCreated, AccountResponse{
+		Success: true,
+		Message: "创建邮箱账户成功",
+		Data:    account,
+	})
+}
+
+// UpdateAccount 更新邮箱账户
+func UpdateAccount(c *gin.Context) {
+	account, ok := findAccountByParam(c)
+	if !ok {
+		return
+	}
+
+	// 绑定更新数据
+	var req AccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+	updateData := req.Account
+
+	// OAuth2 令牌和同步状态按邮箱地址保存，修改地址会丢失令牌并重新扫描整个邮箱，需要删除后重新创建账户
+	if updateData.Email != "" && !strings.EqualFold(updateData.Email, account.Email) {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "邮箱地址不能修改，请删除后重新创建账户",
+		})
+		return
+	}
+
+	// 更新数据
+	account.Name = updateData.Name
+	account.AuthMode = updateData.AuthMode
+	account.OAuth2ClientID = updateData.OAuth2ClientID
+	account.IMAPHost = updateData.IMAPHost
+	account.IMAPPort = updateData.IMAPPort
+	account.IMAPTLSMode = updateData.IMAPTLSMode
+	account.SMTPHost = updateData.SMTPHost
+	account.SMTPPort = updateData.SMTPPort
+	account.SMTPTLSMode = updateData.SMTPTLSMode
+	account.Folders = updateData.Folders
+	account.GmailLabels = updateData.GmailLabels
+	account.GmailSearch = updateData.GmailSearch
+	account.CheckInterval = updateData.CheckInterval
+	account.SyncMode = updateData.SyncMode
+	account.SendLimitPerMinute = updateData.SendLimitPerMinute
+	account.SendLimitPerDay = updateData.SendLimitPerDay
+	if req.Enabled != nil {
+		account.Enabled = *req.Enabled
+	}
+	if account.AuthMode == "" {
+		account.AuthMode = models.AuthModePassword
+	}
+	if account.Name == "" {
+		account.Name = account.Email
+	}
+	applyAccountSecrets(account, &req)
+
+	if !validateAccountRequest(c, account) {
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Save(account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "更新邮箱账户失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if !saveAccountRefreshToken(c, account, &req) {
+		return
+	}
+	reloadAccounts()
+
+	c.JSON(http.StatusOK, AccountResponse{
+		Success: true,
+		Message: "更新邮箱账户成功",
+		Data:    account,
+	})
+}
+
+// DeleteAccount 删除邮箱账户
+func DeleteAccount(c *gin.Context) {
+	account, ok := findAccountByParam(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+
+	// 解除规则与账户的关联
+	if err := db.Exec("DELETE FROM rule_accounts WHERE account_id = ?", account.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "删除邮箱账户失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 删除记录
+	if err := db.Delete(account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "删除邮箱账户失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	reloadAccounts()
+
+	c.JSON(http.StatusOK, AccountResponse{
+		Success: true,
+		Message: "删除邮箱账户成功",
+	})
+}
+
+// applyAccountSecrets 应用请求中提供的密码和 OAuth2 客户端密钥
+func applyAccountSecrets(account *models.Account, req *AccountRequest) {
+	if req.Password != nil {
+		account.Password = *req.Password
+	}
+	if req.OAuth2ClientSecret != nil {
+		account.OAuth2ClientSecret = *req.OAuth2ClientSecret
+	}
+}
+
+// validateAccountRequest 校验账户配置和认证信息，失败时写入响应并返回 false
+func validateAccountRequest(c *gin.Context, account *models.Account) bool {
+	if err := processor.ValidateAccount(account); err != nil {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "邮箱账户配置无效",
+			Error:   err.Error(),
+		})
+		return false
+	}
+
+	if account.AuthMode == models.AuthModePassword && account.Password == "" {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "密码认证需要设置 password",
+		})
+		return false
+	}
+	if account.AuthMode == models.AuthModeOAuth2 && account.OAuth2ClientID == "" {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "OAuth2 认证需要设置 oauth2_client_id",
+		})
+		return false
+	}
+	return true
+}
+
+// saveAccountRefreshToken 保存请求中提供的 OAuth2 刷新令牌，失败时写入响应并返回 false
+func saveAccountRefreshToken(c *gin.Context, account *models.Account, req *AccountRequest) bool {
+	if req.RefreshToken == nil || *req.RefreshToken == "" {
+		return true
+	}
+
+	if err := processor.StoreRefreshToken(account.Email, *req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, AccountResponse{
+			Success: false,
+			Message: "保存刷新令牌失败",
+			Error:   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// findAccountByParam 根据路径参数 id 查找账户，失败时写入响应并返回 false
+func findAccountByParam(c *gin.Context) (*models.Account, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, AccountResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	db := database.GetDB()
+	var account models.Account
+	if err := db.First(&account, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, AccountResponse{
+			Success: false,
+			Message: "邮箱账户不存在",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &account, true
+}
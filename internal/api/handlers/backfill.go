@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gmail-forwarding/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackfillResponse 回溯处理响应结构
+type BackfillResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// BackfillRequest 回溯处理请求结构，日期格式为 YYYY-MM-DD
+type BackfillRequest struct {
+	AccountID uint   `json:"account_id"` // 只有一个账户时可以不传
+	Mailbox   string `json:"mailbox"`
+	Since     string `json:"since"`
+	Before    string `json:"before"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// 回溯处理任务状态
+const (
+	BackfillStatusRunning   = "running"
+	BackfillStatusCompleted = "completed"
+	BackfillStatusFailed    = "failed"
+)
+
+// BackfillJob 回溯处理任务
+type BackfillJob struct {
+	ID         uint                       `json:"id"`
+	Account    string                     `json:"account"`
+	Status     string                     `json:"status"`
+	Since      string                     `json:"since,omitempty"`
+	Before     string                     `json:"before,omitempty"`
+	Progress   processor.BackfillProgress `json:"progress"`
+	Error      string                     `json:"error,omitempty"`
+	StartedAt  time.Time                  `json:"started_at"`
+	FinishedAt *time.Time                 `json:"finished_at,omitempty"`
+}
+
+// backfillJobs 保存本进程启动以来的回溯处理任务，服务重启后清空
+var backfillJobs = struct {
+	sync.Mutex
+	nextID uint
+	jobs   map[uint]*BackfillJob
+}{jobs: make(map[uint]*BackfillJob)}
+
+// StartBackfill 启动回溯处理任务，任务在后台执行，通过 GET /api/backfill/:id 查询进度
+func StartBackfill(c *gin.Context) {
+	var req BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BackfillResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	opts, err := backfillOptions(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BackfillResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	account, err := processor.ResolveAccount(req.AccountID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BackfillResponse{
+			Success: false,
+			Message: "邮箱账户无效",
+			Error:   err.Error(),
+		})
+		return
+	}
+	emailProcessor, err := processor.NewAccountProcessor(account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, BackfillResponse{
+			Success: false,
+			Message: "邮箱账户配置不完整",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if opts.Mailbox == "" {
+		opts.Mailbox = processor.AccountMailboxes(account)[0]
+	}
+
+	backfillJobs.Lock()
+	backfillJobs.nextID++
+	job := &BackfillJob{
+		ID:        backfillJobs.nextID,
+		Account:   account.Email,
+		Status:    BackfillStatusRunning,
+		Since:     req.Since,
+		Before:    req.Before,
+		Progress:  processor.BackfillProgress{Mailbox: opts.Mailbox, DryRun: opts.DryRun},
+		StartedAt: time.Now(),
+	}
+	backfillJobs.jobs[job.ID] = job
+	snapshot := *job
+	backfillJobs.Unlock()
+
+	go runBackfillJob(job, emailProcessor, opts)
+
+	c.JSON(http.StatusAccepted, BackfillResponse{
+		Success: true,
+		Message: "回溯处理任务已启动",
+		Data:    snapshot,
+	})
+}
+
+// backfillOptions 将请求转换为回溯处理参数
+func backfillOptions(req *BackfillRequest) (processor.BackfillOptions, error) {
+	opts := processor.BackfillOptions{Mailbox: req.Mailbox, DryRun: req.DryRun}
+
+	var err error
+	if opts.Since, err = processor.ParseBackfillDate(req.Since); err != nil {
+		return opts, err
+	}
+	if opts.Before, err = processor.ParseBackfillDate(req.Before); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// runBackfillJob 执行回溯处理并更新任务进度
+func runBackfillJob(job *BackfillJob, emailProcessor *processor.EmailProcessor, opts processor.BackfillOptions) {
+	// 回溯处理在后台执行，不随请求结束而取消
+	result, err := emailProcessor.Backfill(context.Background(), opts, func(p processor.BackfillProgress) {
+		backfillJobs.Lock()
+		job.Progress = p
+		backfillJobs.Unlock()
+	})
+
+	backfillJobs.Lock()
+	defer backfillJobs.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if result != nil {
+		job.Progress = *result
+	}
+	if err != nil {
+		job.Status = BackfillStatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = BackfillStatusCompleted
+}
+
+// GetBackfillJobs 获取所有回溯处理任务
+func GetBackfillJobs(c *gin.Context) {
+	backfillJobs.Lock()
+	jobs := make([]BackfillJob, 0, len(backfillJobs.jobs))
+	for id := uint(1); id <= backfillJobs.nextID; id++ {
+		if job, ok := backfillJobs.jobs[id]; ok {
+			snapshot := *job
+			snapshot.Progress.Results = nil // 列表中不返回试运行明细
+			jobs = append(jobs, snapshot)
+		}
+	}
+	backfillJobs.Unlock()
+
+	c.JSON(http.StatusOK, BackfillResponse{
+		Success: true,
+		Message: "获取回溯处理任务列表成功",
+		Data:    jobs,
+	})
+}
+
+// GetBackfillJob 获取单个回溯处理任务的进度
+func GetBackfillJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BackfillResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	backfillJobs.Lock()
+	job, ok := backfillJobs.jobs[uint(id)]
+	var snapshot BackfillJob
+	if ok {
+		snapshot = *job
+	}
+	backfillJobs.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, BackfillResponse{
+			Success: false,
+			Message: "回溯处理任务不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, BackfillResponse{
+		Success: true,
+		Message: "获取回溯处理任务成功",
+		Data:    snapshot,
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DestinationFilterResponse 转发目标过滤规则响应结构
+type DestinationFilterResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// DestinationFilterRequest 转发目标过滤规则请求结构
+type DestinationFilterRequest struct {
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
+	Active  *bool  `json:"active"`
+	Note    string `json:"note"`
+}
+
+// GetDestinationFilters 获取所有转发目标过滤规则
+func GetDestinationFilters(c *gin.Context) {
+	db := database.GetDB()
+	var filters []models.DestinationFilter
+
+	query := db
+	if filterType := c.Query("type"); filterType != "" {
+		query = query.Where("type = ?", filterType)
+	}
+
+	if err := query.Find(&filters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, DestinationFilterResponse{
+			Success: false,
+			Message: "获取转发目标过滤规则列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DestinationFilterResponse{
+		Success: true,
+		Message: "获取转发目标过滤规则列表成功",
+		Data:    filters,
+	})
+}
+
+// GetDestinationFilter 获取单个转发目标过滤规则
+func GetDestinationFilter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var filter models.DestinationFilter
+
+	if err := db.First(&filter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, DestinationFilterResponse{
+			Success: false,
+			Message: "转发目标过滤规则不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DestinationFilterResponse{
+		Success: true,
+		Message: "获取转发目标过滤规则成功",
+		Data:    filter,
+	})
+}
+
+// CreateDestinationFilter 创建转发目标过滤规则
+func CreateDestinationFilter(c *gin.Context) {
+	var req DestinationFilterRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filter := models.DestinationFilter{
+		Pattern: req.Pattern,
+		Type:    req.Type,
+		Active:  req.Active == nil || *req.Active,
+		Note:    req.Note,
+	}
+
+	// 校验并规范化匹配模式
+	if err := filter.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "转发目标过滤规则不合法",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Create(&filter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, DestinationFilterResponse{
+			Success: false,
+			Message: "创建转发目标过滤规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, DestinationFilterResponse{
+		Success: true,
+		Message: "创建转发目标过滤规则成功",
+		Data:    filter,
+	})
+}
+
+// UpdateDestinationFilter 更新转发目标过滤规则
+func UpdateDestinationFilter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var filter models.DestinationFilter
+
+	// 检查记录是否存在
+	if err := db.First(&filter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, DestinationFilterResponse{
+			Success: false,
+			Message: "转发目标过滤规则不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 绑定更新数据
+	var req DestinationFilterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 更新数据
+	filter.Pattern = req.Pattern
+	filter.Type = req.Type
+	filter.Note = req.Note
+	if req.Active != nil {
+		filter.Active = *req.Active
+	}
+
+	if err := filter.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "转发目标过滤规则不合法",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := db.Save(&filter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, DestinationFilterResponse{
+			Success: false,
+			Message: "更新转发目标过滤规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DestinationFilterResponse{
+		Success: true,
+		Message: "更新转发目标过滤规则成功",
+		Data:    filter,
+	})
+}
+
+// DeleteDestinationFilter 删除转发目标过滤规则
+func DeleteDestinationFilter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DestinationFilterResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var filter models.DestinationFilter
+
+	// 检查记录是否存在
+	if err := db.First(&filter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, DestinationFilterResponse{
+			Success: false,
+			Message: "转发目标过滤规则不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 删除记录（硬删除，便于重新创建相同的匹配模式）
+	if err := db.Unscoped().Delete(&filter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, DestinationFilterResponse{
+			Success: false,
+			Message: "删除转发目标过滤规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DestinationFilterResponse{
+		Success: true,
+		Message: "删除转发目标过滤规则成功",
+	})
+}
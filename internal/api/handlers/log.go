@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// LogResponse 转发日志响应结构
+type LogResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// LogPage 转发日志分页数据
+type LogPage struct {
+	Items    []models.ForwardLog `json:"items"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+}
+
+// GetLogs 分页查询转发日志
+//
+// 支持的查询参数：page, page_size, status, keyword, target_email,
+// message_id, rule_id, account_id, mailbox, from, error_kind, start, end（时间格式为 RFC3339）
+func GetLogs(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	db := database.GetDB()
+	query := db.Model(&models.ForwardLog{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if errorKind := c.Query("error_kind"); errorKind != "" {
+		query = query.Where("error_kind = ?", errorKind)
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("keyword = ?", keyword)
+	}
+	if targetEmail := c.Query("target_email"); targetEmail != "" {
+		query = query.Where("target_email = ?", targetEmail)
+	}
+	if messageID := c.Query("message_id"); messageID != "" {
+		query = query.Where("message_id = ?", messageID)
+	}
+	if mailbox := c.Query("mailbox"); mailbox != "" {
+		query = query.Where("mailbox = ?", mailbox)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("from_address LIKE ?", "%"+from+"%")
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		id, err := strconv.ParseUint(ruleID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, LogResponse{
+				Success: false,
+				Message: "无效的rule_id参数",
+				Error:   err.Error(),
+			})
+			return
+		}
+		query = query.Where("rule_id = ?", id)
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		id, err := strconv.ParseUint(accountID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, LogResponse{
+				Success: false,
+				Message: "无效的account_id参数",
+				Error:   err.Error(),
+			})
+			return
+		}
+		query = query.Where("account_id = ?", id)
+	}
+	for param, cond := range map[string]string{"start": "processed_at >= ?", "end": "processed_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, LogResponse{
+				Success: false,
+				Message: "无效的" + param + "参数，应为RFC3339时间格式",
+				Error:   err.Error(),
+			})
+			return
+		}
+		query = query.Where(cond, t)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, LogResponse{
+			Success: false,
+			Message: "获取转发日志失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var logs []models.ForwardLog
+	err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&logs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LogResponse{
+			Success: false,
+			Message: "获取转发日志失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LogResponse{
+		Success: true,
+		Message: "获取转发日志成功",
+		Data: LogPage{
+			Items:    logs,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		},
+	})
+}
+
+// parsePagination 解析分页参数，非法值使用默认值
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
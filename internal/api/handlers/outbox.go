@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+	"gmail-forwarding/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OutboxResponse 发送队列响应结构
+type OutboxResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// OutboxPage 发送任务分页数据
+type OutboxPage struct {
+	Items    []models.OutboxJob `json:"items"`
+	Total    int64              `json:"total"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+}
+
+// SendQuotas 查询发送限额使用情况，由启动流程设置为发送队列的 Quotas
+var SendQuotas func() (*processor.SendQuotas, error)
+
+// GetOutboxJobs 分页查询发送任务
+//
+// 支持的查询参数：page, page_size, status, account_id, target_email, error_kind
+func GetOutboxJobs(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	db := database.GetDB()
+	query := db.Model(&models.OutboxJob{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if errorKind := c.Query("error_kind"); errorKind != "" {
+		query = query.Where("error_kind = ?", errorKind)
+	}
+	if targetEmail := c.Query("target_email"); targetEmail != "" {
+		query = query.Where("target_email = ?", targetEmail)
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		id, err := strconv.ParseUint(accountID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, OutboxResponse{
+				Success: false,
+				Message: "无效的account_id参数",
+				Error:   err.Error(),
+			})
+			return
+		}
+		query = query.Where("account_id = ?", id)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, OutboxResponse{
+			Success: false,
+			Message: "获取发送任务失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var jobs []models.OutboxJob
+	err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&jobs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, OutboxResponse{
+			Success: false,
+			Message: "获取发送任务失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResponse{
+		Success: true,
+		Message: "获取发送任务成功",
+		Data: OutboxPage{
+			Items:    jobs,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		},
+	})
+}
+
+// GetOutboxJob 获取单个发送任务
+func GetOutboxJob(c *gin.Context) {
+	job, ok := findOutboxJobByParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResponse{
+		Success: true,
+		Message: "获取发送任务成功",
+		Data:    job,
+	})
+}
+
+// RetryOutboxJob 立即重试死信或等待重试的发送任务
+func RetryOutboxJob(c *gin.Context) {
+	job, ok := findOutboxJobByParam(c)
+	if !ok {
+		return
+	}
+
+	if err := processor.RetryOutboxJob(job); err != nil {
+		c.JSON(http.StatusConflict, OutboxResponse{
+			Success: false,
+			Message: "重试发送任务失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResponse{
+		Success: true,
+		Message: "发送任务已重新加入队列",
+		Data:    job,
+	})
+}
+
+// DiscardOutboxJob 丢弃未发送的任务
+func DiscardOutboxJob(c *gin.Context) {
+	job, ok := findOutboxJobByParam(c)
+	if !ok {
+		return
+	}
+
+	if err := processor.DiscardOutboxJob(job); err != nil {
+		c.JSON(http.StatusConflict, OutboxResponse{
+			Success: false,
+			Message: "丢弃发送任务失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResponse{
+		Success: true,
+		Message: "发送任务已丢弃",
+		Data:    job,
+	})
+}
+
+// GetSendQuotas 获取各账户和收件人的发送限额使用情况
+func GetSendQuotas(c *gin.Context) {
+	if SendQuotas == nil {
+		c.JSON(http.StatusServiceUnavailable, OutboxResponse{
+			Success: false,
+			Message: "发送队列未启动",
+		})
+		return
+	}
+
+	quotas, err := SendQuotas()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, OutboxResponse{
+			Success: false,
+			Message: "获取发送限额失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResponse{
+		Success: true,
+		Message: "获取发送限额成功",
+		Data:    quotas,
+	})
+}
+
+// findOutboxJobByParam 根据路径参数 id 查找发送任务，失败时写入响应并返回 false
+func findOutboxJobByParam(c *gin.Context) (*models.OutboxJob, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, OutboxResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	db := database.GetDB()
+	var job models.OutboxJob
+	if err := db.First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, OutboxResponse{
+			Success: false,
+			Message: "发送任务不存在",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &job, true
+}
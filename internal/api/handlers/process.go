@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 
-	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
 	"gmail-forwarding/internal/processor"
 
 	"github.com/gin-gonic/gin"
@@ -17,34 +20,36 @@ type ProcessResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
-// ProcessEmails 手动触发邮件处理
+// ProcessEmails 手动触发邮件处理，指定 account_id 时只处理该账户，否则依次处理所有启用的账户；
+// 客户端断开连接时中断处理
 func ProcessEmails(c *gin.Context) {
-	// 获取Gmail配置
-	gmailUser := os.Getenv("GMAIL_USER")
-	gmailPassword := os.Getenv("GMAIL_APP_PASSWORD")
-
-	if gmailUser == "" || gmailPassword == "" {
-		c.JSON(http.StatusInternalServerError, ProcessResponse{
+	accounts, err := processAccounts(c.Query("account_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProcessResponse{
 			Success: false,
-			Message: "Gmail配置不完整",
-			Error:   "请检查GMAIL_USER和GMAIL_APP_PASSWORD环境变量",
+			Message: "邮箱账户无效",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// 创建客户端
-	imapClient := gmail.NewIMAPClient(gmailUser, gmailPassword)
-	smtpClient := gmail.NewSMTPClient(gmailUser, gmailPassword)
-
-	// 创建处理器
-	emailProcessor := processor.NewEmailProcessor(imapClient, smtpClient)
+	var errs []string
+	for i := range accounts {
+		// 每个账户使用独立的处理器
+		emailProcessor, err := processor.NewAccountProcessor(&accounts[i])
+		if err == nil {
+			err = emailProcessor.ProcessEmails(c.Request.Context())
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", accounts[i].Email, err))
+		}
+	}
 
-	// 处理邮件
-	if err := emailProcessor.ProcessEmails(); err != nil {
+	if len(errs) > 0 {
 		c.JSON(http.StatusInternalServerError, ProcessResponse{
 			Success: false,
 			Message: "邮件处理失败",
-			Error:   err.Error(),
+			Error:   strings.Join(errs, "; "),
 		})
 		return
 	}
@@ -55,4 +60,23 @@ func ProcessEmails(c *gin.Context) {
 	})
 }
 
+// processAccounts 解析 account_id 参数，为空时返回所有启用的账户
+func processAccounts(accountID string) ([]models.Account, error) {
+	if accountID == "" {
+		accounts, err := processor.LoadAccounts()
+		if err == nil && len(accounts) == 0 {
+			err = errors.New("没有可用的邮箱账户，请配置GMAIL_USER或通过API添加账户")
+		}
+		return accounts, err
+	}
 
+	id, err := strconv.ParseUint(accountID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("无效的account_id参数: %w", err)
+	}
+	account, err := processor.ResolveAccount(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	return []models.Account{*account}, nil
+}
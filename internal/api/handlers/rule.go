@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"gmail-forwarding/internal/database"
 	"gmail-forwarding/internal/models"
+	"gmail-forwarding/internal/processor"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // RuleResponse 转发规则响应结构
@@ -18,12 +21,22 @@ type RuleResponse struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// RuleRequest 转发规则请求结构
+//
+// recipient_ids 为规则绑定的固定收件人ID列表，account_ids 为规则适用的邮箱账户ID列表（为空表示适用于所有账户），
+// 更新时不传表示保持不变，传空数组表示清空。
+type RuleRequest struct {
+	models.ForwardingRule
+	RecipientIDs *[]uint `json:"recipient_ids"`
+	AccountIDs   *[]uint `json:"account_ids"`
+}
+
 // GetRules 获取所有转发规则
 func GetRules(c *gin.Context) {
 	db := database.GetDB()
 	var rules []models.ForwardingRule
 
-	if err := db.Find(&rules).Error; err != nil {
+	if err := db.Preload("Recipients").Preload("Accounts").Find(&rules).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, RuleResponse{
 			Success: false,
 			Message: "获取转发规则列表失败",
@@ -54,7 +67,7 @@ func GetRule(c *gin.Context) {
 	db := database.GetDB()
 	var rule models.ForwardingRule
 
-	if err := db.First(&rule, id).Error; err != nil {
+	if err := db.Preload("Recipients").Preload("Accounts").First(&rule, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, RuleResponse{
 			Success: false,
 			Message: "转发规则不存在",
@@ -72,9 +85,9 @@ func GetRule(c *gin.Context) {
 
 // CreateRule 创建转发规则
 func CreateRule(c *gin.Context) {
-	var rule models.ForwardingRule
+	var req RuleRequest
 
-	if err := c.ShouldBindJSON(&rule); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, RuleResponse{
 			Success: false,
 			Message: "请求参数错误",
@@ -82,6 +95,9 @@ func CreateRule(c *gin.Context) {
 		})
 		return
 	}
+	rule := req.ForwardingRule
+	rule.Recipients = nil
+	rule.Accounts = nil
 
 	// 验证必填字段
 	if rule.Keyword == "" {
@@ -96,8 +112,60 @@ func CreateRule(c *gin.Context) {
 	if !c.Request.URL.Query().Has("active") {
 		rule.Active = true
 	}
+	if rule.ForwardMode == "" {
+		rule.ForwardMode = models.ForwardModeInline
+	}
+	if !models.ValidForwardMode(rule.ForwardMode) {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "转发方式只能为 inline 或 attachment",
+		})
+		return
+	}
+	if rule.MatchType == "" {
+		rule.MatchType = models.MatchTypeExact
+	}
+
+	// 校验匹配模式（如正则表达式是否合法）和条件树
+	if err := processor.ValidateRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "匹配规则无效",
+			Error:   err.Error(),
+		})
+		return
+	}
 
 	db := database.GetDB()
+
+	// 绑定固定收件人
+	if req.RecipientIDs != nil {
+		recipients, err := findRecipientsByIDs(*req.RecipientIDs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, RuleResponse{
+				Success: false,
+				Message: "固定收件人无效",
+				Error:   err.Error(),
+			})
+			return
+		}
+		rule.Recipients = recipients
+	}
+
+	// 限定适用账户
+	if req.AccountIDs != nil {
+		accounts, err := findAccountsByIDs(*req.AccountIDs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, RuleResponse{
+				Success: false,
+				Message: "适用账户无效",
+				Error:   err.Error(),
+			})
+			return
+		}
+		rule.Accounts = accounts
+	}
+
 	if err := db.Create(&rule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, RuleResponse{
 			Success: false,
@@ -140,8 +208,8 @@ func UpdateRule(c *gin.Context) {
 	}
 
 	// 绑定更新数据
-	var updateData models.ForwardingRule
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, RuleResponse{
 			Success: false,
 			Message: "请求参数错误",
@@ -149,6 +217,7 @@ func UpdateRule(c *gin.Context) {
 		})
 		return
 	}
+	updateData := req.ForwardingRule
 
 	// 验证必填字段
 	if updateData.Keyword == "" {
@@ -159,9 +228,42 @@ func UpdateRule(c *gin.Context) {
 		return
 	}
 
+	if updateData.ForwardMode == "" {
+		updateData.ForwardMode = models.ForwardModeInline
+	}
+	if !models.ValidForwardMode(updateData.ForwardMode) {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "转发方式只能为 inline 或 attachment",
+		})
+		return
+	}
+	if updateData.MatchType == "" {
+		updateData.MatchType = models.MatchTypeExact
+	}
+
+	// 校验匹配模式（如正则表达式是否合法）和条件树
+	if err := processor.ValidateRule(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "匹配规则无效",
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	// 更新数据
 	rule.Keyword = updateData.Keyword
 	rule.Active = updateData.Active
+	rule.ForwardMode = updateData.ForwardMode
+	rule.RequireSenderAuth = updateData.RequireSenderAuth
+	rule.MatchType = updateData.MatchType
+	rule.IgnoreCase = updateData.IgnoreCase
+	rule.Conditions = updateData.Conditions
+	rule.Priority = updateData.Priority
+	rule.StopProcessing = updateData.StopProcessing
+	rule.SuccessActions = updateData.SuccessActions
+	rule.FailureActions = updateData.FailureActions
 
 	if err := db.Save(&rule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, RuleResponse{
@@ -172,6 +274,57 @@ func UpdateRule(c *gin.Context) {
 		return
 	}
 
+	// 更新固定收件人
+	if req.RecipientIDs != nil {
+		recipients, err := findRecipientsByIDs(*req.RecipientIDs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, RuleResponse{
+				Success: false,
+				Message: "固定收件人无效",
+				Error:   err.Error(),
+			})
+			return
+		}
+		if err := db.Model(&rule).Association("Recipients").Replace(recipients); err != nil {
+			c.JSON(http.StatusInternalServerError, RuleResponse{
+				Success: false,
+				Message: "更新固定收件人失败",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	// 更新适用账户
+	if req.AccountIDs != nil {
+		accounts, err := findAccountsByIDs(*req.AccountIDs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, RuleResponse{
+				Success: false,
+				Message: "适用账户无效",
+				Error:   err.Error(),
+			})
+			return
+		}
+		if err := db.Model(&rule).Association("Accounts").Replace(accounts); err != nil {
+			c.JSON(http.StatusInternalServerError, RuleResponse{
+				Success: false,
+				Message: "更新适用账户失败",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := db.Preload("Recipients").Preload("Accounts").First(&rule, rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, RuleResponse{
+			Success: false,
+			Message: "获取转发规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, RuleResponse{
 		Success: true,
 		Message: "更新转发规则成功",
@@ -204,6 +357,18 @@ func DeleteRule(c *gin.Context) {
 		return
 	}
 
+	// 解除固定收件人和适用账户绑定
+	for _, association := range []string{"Recipients", "Accounts"} {
+		if err := db.Model(&rule).Association(association).Clear(); err != nil {
+			c.JSON(http.StatusInternalServerError, RuleResponse{
+				Success: false,
+				Message: "删除转发规则失败",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
 	// 删除记录
 	if err := db.Delete(&rule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, RuleResponse{
@@ -218,4 +383,158 @@ func DeleteRule(c *gin.Context) {
 		Success: true,
 		Message: "删除转发规则成功",
 	})
-}
\ No newline at end of file
+}
+
+// GetRuleRecipients 获取规则绑定的固定收件人
+func GetRuleRecipients(c *gin.Context) {
+	rule, ok := findRuleByParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, RuleResponse{
+		Success: true,
+		Message: "获取固定收件人成功",
+		Data:    rule.Recipients,
+	})
+}
+
+// AddRuleRecipient 为规则绑定固定收件人
+func AddRuleRecipient(c *gin.Context) {
+	updateRuleRecipient(c, func(association *gorm.Association, recipient *models.Recipient) error {
+		return association.Append(recipient)
+	}, "绑定固定收件人")
+}
+
+// RemoveRuleRecipient 解除规则绑定的固定收件人
+func RemoveRuleRecipient(c *gin.Context) {
+	updateRuleRecipient(c, func(association *gorm.Association, recipient *models.Recipient) error {
+		return association.Delete(recipient)
+	}, "解除固定收件人")
+}
+
+// updateRuleRecipient 对规则的固定收件人执行绑定或解除操作
+func updateRuleRecipient(c *gin.Context, op func(*gorm.Association, *models.Recipient) error, action string) {
+	rule, ok := findRuleByParam(c)
+	if !ok {
+		return
+	}
+
+	recipientID, err := strconv.ParseUint(c.Param("recipient_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "无效的recipient_id参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var recipient models.Recipient
+	if err := db.First(&recipient, recipientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, RuleResponse{
+			Success: false,
+			Message: "转发对象不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := op(db.Model(rule).Association("Recipients"), &recipient); err != nil {
+		c.JSON(http.StatusInternalServerError, RuleResponse{
+			Success: false,
+			Message: action + "失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := db.Preload("Recipients").Preload("Accounts").First(rule, rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, RuleResponse{
+			Success: false,
+			Message: "获取转发规则失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RuleResponse{
+		Success: true,
+		Message: action + "成功",
+		Data:    rule,
+	})
+}
+
+// findRuleByParam 根据路径参数 id 查找规则（包含固定收件人），失败时写入响应并返回 false
+func findRuleByParam(c *gin.Context) (*models.ForwardingRule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+
+	db := database.GetDB()
+	var rule models.ForwardingRule
+	if err := db.Preload("Recipients").Preload("Accounts").First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, RuleResponse{
+			Success: false,
+			Message: "转发规则不存在",
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &rule, true
+}
+
+// findRecipientsByIDs 根据ID列表查找转发对象，任一ID不存在时返回错误
+func findRecipientsByIDs(ids []uint) ([]models.Recipient, error) {
+	recipients := []models.Recipient{}
+	if len(ids) == 0 {
+		return recipients, nil
+	}
+
+	db := database.GetDB()
+	if err := db.Where("id IN ?", ids).Find(&recipients).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(recipients))
+	for _, r := range recipients {
+		found[r.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("转发对象 %d 不存在", id)
+		}
+	}
+	return recipients, nil
+}
+
+// findAccountsByIDs 根据ID列表查找邮箱账户，任一ID不存在时返回错误
+func findAccountsByIDs(ids []uint) ([]models.Account, error) {
+	accounts := []models.Account{}
+	if len(ids) == 0 {
+		return accounts, nil
+	}
+
+	db := database.GetDB()
+	if err := db.Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(accounts))
+	for _, a := range accounts {
+		found[a.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("邮箱账户 %d 不存在", id)
+		}
+	}
+	return accounts, nil
+}
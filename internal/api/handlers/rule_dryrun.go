@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/processor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RuleTestRequest 规则试运行请求结构
+//
+// 可以提供构造的邮件（subject/from/to/cc/headers/body/html），也可以通过 raw 提供原始 RFC822 邮件；
+// 以 multipart/form-data 上传时，原始邮件放在 file 字段中。
+type RuleTestRequest struct {
+	Subject         string            `json:"subject" form:"subject"`
+	From            string            `json:"from" form:"from"`
+	To              []string          `json:"to" form:"to"`
+	Cc              []string          `json:"cc" form:"cc"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body" form:"body"`
+	HTML            string            `json:"html" form:"html"`
+	Raw             string            `json:"raw" form:"raw"`
+	AccountID       uint              `json:"account_id" form:"account_id"` // 按该账户的规则范围试运行
+	IncludeInactive bool              `json:"include_inactive" form:"include_inactive"`
+}
+
+// TestRules 使用构造的邮件或原始邮件试运行转发规则，不连接 IMAP/SMTP
+func TestRules(c *gin.Context) {
+	var req RuleTestRequest
+	raw, err := bindRuleTestRequest(c, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if raw == nil {
+		raw = buildTestMessage(&req)
+	}
+
+	email, err := gmail.ParseEmail(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "邮件解析失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 客户端只用于渲染转发邮件，不会连接 IMAP/SMTP 服务器
+	emailProcessor, err := dryRunProcessor(req.AccountID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, RuleResponse{
+			Success: false,
+			Message: "邮箱账户无效",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := emailProcessor.DryRun(email, req.IncludeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, RuleResponse{
+			Success: false,
+			Message: "规则试运行失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RuleResponse{
+		Success: true,
+		Message: "规则试运行完成",
+		Data:    result,
+	})
+}
+
+// dryRunProcessor 创建试运行使用的处理器，未指定账户时只使用未限定账户的规则
+func dryRunProcessor(accountID uint) (*processor.EmailProcessor, error) {
+	if accountID == 0 {
+		return processor.NewDryRunProcessor(nil), nil
+	}
+
+	account, err := processor.ResolveAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return processor.NewDryRunProcessor(account), nil
+}
+
+// bindRuleTestRequest 解析试运行请求，返回上传或提交的原始邮件（未提供时为 nil）
+func bindRuleTestRequest(c *gin.Context, req *RuleTestRequest) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		if err := c.ShouldBind(req); err != nil {
+			return nil, err
+		}
+		file, err := c.FormFile("file")
+		if err == http.ErrMissingFile {
+			return rawOrNil(req.Raw), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		return nil, err
+	}
+	return rawOrNil(req.Raw), nil
+}
+
+// rawOrNil 将提交的原始邮件文本转为字节，为空时返回 nil
+func rawOrNil(raw string) []byte {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return []byte(raw)
+}
+
+// buildTestMessage 根据构造的邮件字段生成 RFC822 邮件，使其与实际收取的邮件经过相同的解析流程
+func buildTestMessage(req *RuleTestRequest) []byte {
+	var b strings.Builder
+
+	header := map[string]string{
+		"From":         req.From,
+		"To":           strings.Join(req.To, ", "),
+		"Cc":           strings.Join(req.Cc, ", "),
+		"Subject":      mime.QEncoding.Encode("utf-8", req.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+	}
+	for key, value := range req.Headers {
+		header[key] = value
+	}
+
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if header[key] != "" && !strings.EqualFold(key, "Content-Type") {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, header[key])
+		}
+	}
+
+	if req.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		b.WriteString(req.Body)
+		return []byte(b.String())
+	}
+
+	boundary := "dry-run-boundary"
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, req.Body)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n", boundary, req.HTML)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return []byte(b.String())
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedSenderResponse 可信发件人响应结构
+type TrustedSenderResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// TrustedSenderRequest 可信发件人请求结构，rule_id 为空表示全局可信发件人
+type TrustedSenderRequest struct {
+	Pattern string `json:"pattern"`
+	RuleID  *uint  `json:"rule_id"`
+	Active  *bool  `json:"active"`
+	Note    string `json:"note"`
+}
+
+// GetTrustedSenders 获取所有可信发件人
+func GetTrustedSenders(c *gin.Context) {
+	db := database.GetDB()
+	var senders []models.TrustedSender
+
+	// rule_id=global 只查询全局可信发件人
+	query := db
+	switch ruleID := c.Query("rule_id"); ruleID {
+	case "":
+	case "global":
+		query = query.Where("rule_id IS NULL")
+	default:
+		id, err := strconv.ParseUint(ruleID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+				Success: false,
+				Message: "无效的rule_id参数",
+				Error:   err.Error(),
+			})
+			return
+		}
+		query = query.Where("rule_id = ?", id)
+	}
+
+	if err := query.Find(&senders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, TrustedSenderResponse{
+			Success: false,
+			Message: "获取可信发件人列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TrustedSenderResponse{
+		Success: true,
+		Message: "获取可信发件人列表成功",
+		Data:    senders,
+	})
+}
+
+// GetTrustedSender 获取单个可信发件人
+func GetTrustedSender(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var sender models.TrustedSender
+
+	if err := db.First(&sender, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, TrustedSenderResponse{
+			Success: false,
+			Message: "可信发件人不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TrustedSenderResponse{
+		Success: true,
+		Message: "获取可信发件人成功",
+		Data:    sender,
+	})
+}
+
+// CreateTrustedSender 创建可信发件人
+func CreateTrustedSender(c *gin.Context) {
+	var req TrustedSenderRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	sender := models.TrustedSender{
+		Pattern: req.Pattern,
+		RuleID:  req.RuleID,
+		Active:  req.Active == nil || *req.Active,
+		Note:    req.Note,
+	}
+
+	if !validateTrustedSender(c, &sender) {
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Create(&sender).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, TrustedSenderResponse{
+			Success: false,
+			Message: "创建可信发件人失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TrustedSenderResponse{
+		Success: true,
+		Message: "创建可信发件人成功",
+		Data:    sender,
+	})
+}
+
+// UpdateTrustedSender 更新可信发件人
+func UpdateTrustedSender(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var sender models.TrustedSender
+
+	// 检查记录是否存在
+	if err := db.First(&sender, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, TrustedSenderResponse{
+			Success: false,
+			Message: "可信发件人不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 绑定更新数据
+	var req TrustedSenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "请求参数错误",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 更新数据
+	sender.Pattern = req.Pattern
+	sender.RuleID = req.RuleID
+	sender.Note = req.Note
+	if req.Active != nil {
+		sender.Active = *req.Active
+	}
+
+	if !validateTrustedSender(c, &sender) {
+		return
+	}
+
+	if err := db.Save(&sender).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, TrustedSenderResponse{
+			Success: false,
+			Message: "更新可信发件人失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TrustedSenderResponse{
+		Success: true,
+		Message: "更新可信发件人成功",
+		Data:    sender,
+	})
+}
+
+// DeleteTrustedSender 删除可信发件人
+func DeleteTrustedSender(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "无效的ID参数",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var sender models.TrustedSender
+
+	// 检查记录是否存在
+	if err := db.First(&sender, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, TrustedSenderResponse{
+			Success: false,
+			Message: "可信发件人不存在",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 删除记录
+	if err := db.Delete(&sender).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, TrustedSenderResponse{
+			Success: false,
+			Message: "删除可信发件人失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TrustedSenderResponse{
+		Success: true,
+		Message: "删除可信发件人成功",
+	})
+}
+
+// validateTrustedSender 校验并规范化可信发件人，校验失败时写入响应并返回 false
+func validateTrustedSender(c *gin.Context, sender *models.TrustedSender) bool {
+	if err := sender.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+			Success: false,
+			Message: "可信发件人不合法",
+			Error:   err.Error(),
+		})
+		return false
+	}
+
+	// 检查所属规则是否存在
+	if sender.RuleID != nil {
+		db := database.GetDB()
+		if err := db.First(&models.ForwardingRule{}, *sender.RuleID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, TrustedSenderResponse{
+				Success: false,
+				Message: "所属转发规则不存在",
+				Error:   err.Error(),
+			})
+			return false
+		}
+	}
+
+	return true
+}
@@ -30,6 +30,16 @@ func SetupRoutes() *gin.Engine {
 	// API 路由组
 	api := router.Group("/api")
 	{
+		// 邮箱账户管理
+		accounts := api.Group("/accounts")
+		{
+			accounts.GET("", handlers.GetAccounts)
+			accounts.GET("/:id", handlers.GetAccount)
+			accounts.POST("", handlers.CreateAccount)
+			accounts.PUT("/:id", handlers.UpdateAccount)
+			accounts.DELETE("/:id", handlers.DeleteAccount)
+		}
+
 		// 转发对象管理
 		recipients := api.Group("/recipients")
 		{
@@ -44,16 +54,62 @@ func SetupRoutes() *gin.Engine {
 		rules := api.Group("/rules")
 		{
 			rules.GET("", handlers.GetRules)
+			rules.POST("/test", handlers.TestRules)
 			rules.GET("/:id", handlers.GetRule)
 			rules.POST("", handlers.CreateRule)
 			rules.PUT("/:id", handlers.UpdateRule)
 			rules.DELETE("/:id", handlers.DeleteRule)
+			rules.GET("/:id/recipients", handlers.GetRuleRecipients)
+			rules.POST("/:id/recipients/:recipient_id", handlers.AddRuleRecipient)
+			rules.DELETE("/:id/recipients/:recipient_id", handlers.RemoveRuleRecipient)
+		}
+
+		// 转发目标白名单/黑名单管理
+		destinationFilters := api.Group("/destination-filters")
+		{
+			destinationFilters.GET("", handlers.GetDestinationFilters)
+			destinationFilters.GET("/:id", handlers.GetDestinationFilter)
+			destinationFilters.POST("", handlers.CreateDestinationFilter)
+			destinationFilters.PUT("/:id", handlers.UpdateDestinationFilter)
+			destinationFilters.DELETE("/:id", handlers.DeleteDestinationFilter)
+		}
+
+		// 可信发件人管理
+		trustedSenders := api.Group("/trusted-senders")
+		{
+			trustedSenders.GET("", handlers.GetTrustedSenders)
+			trustedSenders.GET("/:id", handlers.GetTrustedSender)
+			trustedSenders.POST("", handlers.CreateTrustedSender)
+			trustedSenders.PUT("/:id", handlers.UpdateTrustedSender)
+			trustedSenders.DELETE("/:id", handlers.DeleteTrustedSender)
+		}
+
+		// 转发日志
+		api.GET("/logs", handlers.GetLogs)
+
+		// 发送队列
+		outbox := api.Group("/outbox")
+		{
+			outbox.GET("", handlers.GetOutboxJobs)
+			outbox.GET("/:id", handlers.GetOutboxJob)
+			outbox.POST("/:id/retry", handlers.RetryOutboxJob)
+			outbox.POST("/:id/discard", handlers.DiscardOutboxJob)
 		}
 
+		// 发送限额使用情况
+		api.GET("/quotas", handlers.GetSendQuotas)
 
 		// 邮件处理
 		api.POST("/process", handlers.ProcessEmails)
+
+		// 回溯处理历史邮件
+		backfill := api.Group("/backfill")
+		{
+			backfill.GET("", handlers.GetBackfillJobs)
+			backfill.GET("/:id", handlers.GetBackfillJob)
+			backfill.POST("", handlers.StartBackfill)
+		}
 	}
 
 	return router
-}
\ No newline at end of file
+}
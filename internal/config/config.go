@@ -3,6 +3,11 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
 )
 
 // Config 应用配置结构
@@ -18,11 +23,63 @@ type Config struct {
 	GmailUser     string
 	GmailPassword string
 
+	// 认证配置
+	AuthMode           string // password: 应用专用密码; oauth2: XOAUTH2
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2TokenURL     string
+	OAuth2RefreshToken string // 初始刷新令牌，数据库中已保存刷新令牌时忽略
+
+	// 邮件服务器配置，默认使用 Gmail
+	IMAPHost               string
+	IMAPPort               string
+	IMAPTLSMode            string // tls: 直接 TLS; starttls: STARTTLS 升级; plain: 不加密（仅测试）
+	IMAPCAFile             string
+	IMAPInsecureSkipVerify bool
+	SMTPHost               string
+	SMTPPort               string
+	SMTPTLSMode            string
+	SMTPCAFile             string
+	SMTPInsecureSkipVerify bool
+	IMAPTimeout            string // 连接和单个 IMAP 命令的超时时间
+	SMTPTimeout            string // 连接和每封邮件发送的超时时间
+
+	// 邮箱文件夹配置（默认账户）
+	Folders     []string // 要处理的邮箱文件夹，默认 INBOX
+	GmailLabels []string // X-GM-LABELS 标签过滤
+	GmailSearch string   // X-GM-RAW 搜索语法过滤
+
 	// 应用配置
 	AppPort       string
 	CheckInterval string
+
+	// 同步模式配置
+	SyncMode            string // cron: 仅定时轮询; idle: IMAP IDLE 推送 + 定时轮询兜底
+	IdleRestartInterval string
+
+	// 发件人认证配置
+	RequireSenderAuth bool   // 是否对所有规则要求 Authentication-Results 通过
+	AuthServID        string // 信任的 Authentication-Results authserv-id
+
+	// 发送队列配置
+	OutboxWorkers      string // 并发发送数
+	OutboxMaxAttempts  string // 最大尝试次数，超过后进入死信
+	OutboxRetryBase    string // 首次重试间隔，之后按指数增长
+	OutboxRetryMax     string // 最大重试间隔
+	OutboxPollInterval string // 轮询待发送任务的间隔
+
+	// 发送限额配置，0 表示不限制
+	SendLimitPerMinute        string // 每个账户每分钟最多发送数，账户可单独设置
+	SendLimitPerDay           string // 每个账户每天最多发送数，账户可单独设置
+	SendLimitPerRecipientHour string // 每个收件人每小时最多接收数
 }
 
+// 同步模式
+const (
+	SyncModeCron = "cron"
+	SyncModeIdle = "idle"
+)
+
 // GlobalConfig 全局配置实例
 var GlobalConfig *Config
 
@@ -40,9 +97,55 @@ func Load() {
 		GmailUser:     getEnv("GMAIL_USER", ""),
 		GmailPassword: getEnv("GMAIL_APP_PASSWORD", ""),
 
+		// 认证配置
+		AuthMode:           getEnv("AUTH_MODE", models.AuthModePassword),
+		OAuth2ClientID:     getEnv("OAUTH2_CLIENT_ID", ""),
+		OAuth2ClientSecret: getEnv("OAUTH2_CLIENT_SECRET", ""),
+		OAuth2TokenURL:     getEnv("OAUTH2_TOKEN_URL", gmail.DefaultTokenURL),
+		OAuth2RefreshToken: getEnv("OAUTH2_REFRESH_TOKEN", ""),
+
+		// 邮件服务器配置
+		IMAPHost:               getEnv("IMAP_HOST", gmail.DefaultIMAPServer.Host),
+		IMAPPort:               getEnv("IMAP_PORT", gmail.DefaultIMAPServer.Port),
+		IMAPTLSMode:            getEnv("IMAP_TLS_MODE", string(gmail.DefaultIMAPServer.TLSMode)),
+		IMAPCAFile:             getEnv("IMAP_CA_FILE", ""),
+		IMAPInsecureSkipVerify: getEnv("IMAP_INSECURE_SKIP_VERIFY", "false") == "true",
+		SMTPHost:               getEnv("SMTP_HOST", gmail.DefaultSMTPServer.Host),
+		SMTPPort:               getEnv("SMTP_PORT", gmail.DefaultSMTPServer.Port),
+		SMTPTLSMode:            getEnv("SMTP_TLS_MODE", string(gmail.DefaultSMTPServer.TLSMode)),
+		SMTPCAFile:             getEnv("SMTP_CA_FILE", ""),
+		SMTPInsecureSkipVerify: getEnv("SMTP_INSECURE_SKIP_VERIFY", "false") == "true",
+		IMAPTimeout:            getEnv("IMAP_TIMEOUT", gmail.DefaultIMAPTimeout.String()),
+		SMTPTimeout:            getEnv("SMTP_TIMEOUT", gmail.DefaultSMTPTimeout.String()),
+
+		// 邮箱文件夹配置
+		Folders:     splitList(getEnv("IMAP_FOLDERS", "INBOX")),
+		GmailLabels: splitList(getEnv("GMAIL_LABELS", "")),
+		GmailSearch: getEnv("GMAIL_SEARCH", ""),
+
 		// 应用配置
 		AppPort:       getEnv("APP_PORT", "8080"),
 		CheckInterval: getEnv("CHECK_INTERVAL", "5m"),
+
+		// 同步模式配置
+		SyncMode:            getEnv("SYNC_MODE", SyncModeCron),
+		IdleRestartInterval: getEnv("IDLE_RESTART_INTERVAL", "25m"),
+
+		// 发件人认证配置
+		RequireSenderAuth: getEnv("REQUIRE_SENDER_AUTH", "false") == "true",
+		AuthServID:        getEnv("AUTH_SERV_ID", "mx.google.com"),
+
+		// 发送队列配置
+		OutboxWorkers:      getEnv("OUTBOX_WORKERS", "2"),
+		OutboxMaxAttempts:  getEnv("OUTBOX_MAX_ATTEMPTS", "8"),
+		OutboxRetryBase:    getEnv("OUTBOX_RETRY_BASE", "30s"),
+		OutboxRetryMax:     getEnv("OUTBOX_RETRY_MAX", "1h"),
+		OutboxPollInterval: getEnv("OUTBOX_POLL_INTERVAL", "5s"),
+
+		// 发送限额配置
+		SendLimitPerMinute:        getEnv("SEND_LIMIT_PER_MINUTE", "0"),
+		SendLimitPerDay:           getEnv("SEND_LIMIT_PER_DAY", "0"),
+		SendLimitPerRecipientHour: getEnv("SEND_LIMIT_PER_RECIPIENT_HOUR", "0"),
 	}
 
 	// 验证必需的配置
@@ -51,6 +154,40 @@ func Load() {
 	log.Println("配置加载完成")
 }
 
+// IMAPServer 返回 IMAP 服务器连接配置
+func (c *Config) IMAPServer() gmail.ServerConfig {
+	return gmail.ServerConfig{
+		Host:               c.IMAPHost,
+		Port:               c.IMAPPort,
+		TLSMode:            gmail.TLSMode(c.IMAPTLSMode),
+		CAFile:             c.IMAPCAFile,
+		InsecureSkipVerify: c.IMAPInsecureSkipVerify,
+		Timeout:            parseTimeout("IMAP_TIMEOUT", c.IMAPTimeout),
+	}
+}
+
+// SMTPServer 返回 SMTP 服务器连接配置
+func (c *Config) SMTPServer() gmail.ServerConfig {
+	return gmail.ServerConfig{
+		Host:               c.SMTPHost,
+		Port:               c.SMTPPort,
+		TLSMode:            gmail.TLSMode(c.SMTPTLSMode),
+		CAFile:             c.SMTPCAFile,
+		InsecureSkipVerify: c.SMTPInsecureSkipVerify,
+		Timeout:            parseTimeout("SMTP_TIMEOUT", c.SMTPTimeout),
+	}
+}
+
+// parseTimeout 解析超时时间配置，无效时返回 0（使用客户端默认值）
+func parseTimeout(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("无效的%s配置 %s，使用默认值", name, value)
+		return 0
+	}
+	return d
+}
+
 // getEnv 获取环境变量，如果不存在则使用默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -59,22 +196,64 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // validateConfig 验证配置
 func validateConfig() {
+	// 未设置 GMAIL_USER 时只处理数据库中配置的账户
 	if GlobalConfig.GmailUser == "" {
-		log.Fatal("GMAIL_USER 环境变量未设置")
-	}
-
-	if GlobalConfig.GmailPassword == "" {
-		log.Fatal("GMAIL_APP_PASSWORD 环境变量未设置")
+		log.Println("GMAIL_USER 环境变量未设置，仅处理数据库中配置的邮箱账户")
+	} else {
+		validateDefaultAccount()
 	}
 
 	if GlobalConfig.DBPassword == "" {
 		log.Println("警告: DB_PASSWORD 环境变量未设置，可能导致数据库连接失败")
 	}
 
-	log.Printf("Gmail 账户: %s", GlobalConfig.GmailUser)
 	log.Printf("数据库: %s:%s/%s", GlobalConfig.DBHost, GlobalConfig.DBPort, GlobalConfig.DBName)
 	log.Printf("应用端口: %s", GlobalConfig.AppPort)
+	if !gmail.ValidTLSMode(GlobalConfig.IMAPTLSMode) {
+		log.Fatalf("无效的 IMAP_TLS_MODE: %s（可选 tls、starttls、plain）", GlobalConfig.IMAPTLSMode)
+	}
+	if !gmail.ValidTLSMode(GlobalConfig.SMTPTLSMode) {
+		log.Fatalf("无效的 SMTP_TLS_MODE: %s（可选 tls、starttls、plain）", GlobalConfig.SMTPTLSMode)
+	}
+	log.Printf("IMAP 服务器: %s:%s (%s)", GlobalConfig.IMAPHost, GlobalConfig.IMAPPort, GlobalConfig.IMAPTLSMode)
+	log.Printf("SMTP 服务器: %s:%s (%s)", GlobalConfig.SMTPHost, GlobalConfig.SMTPPort, GlobalConfig.SMTPTLSMode)
+	if GlobalConfig.SyncMode != SyncModeCron && GlobalConfig.SyncMode != SyncModeIdle {
+		log.Printf("无效的同步模式 %s，使用默认值 %s", GlobalConfig.SyncMode, SyncModeCron)
+		GlobalConfig.SyncMode = SyncModeCron
+	}
+
 	log.Printf("检查间隔: %s", GlobalConfig.CheckInterval)
-}
\ No newline at end of file
+	log.Printf("同步模式: %s", GlobalConfig.SyncMode)
+}
+
+// validateDefaultAccount 验证通过环境变量配置的默认账户
+func validateDefaultAccount() {
+	switch GlobalConfig.AuthMode {
+	case models.AuthModePassword:
+		if GlobalConfig.GmailPassword == "" {
+			log.Fatal("GMAIL_APP_PASSWORD 环境变量未设置")
+		}
+	case models.AuthModeOAuth2:
+		if GlobalConfig.OAuth2ClientID == "" {
+			log.Fatal("OAUTH2_CLIENT_ID 环境变量未设置")
+		}
+	default:
+		log.Fatalf("无效的 AUTH_MODE: %s（可选 password、oauth2）", GlobalConfig.AuthMode)
+	}
+
+	log.Printf("Gmail 账户: %s (认证方式: %s)", GlobalConfig.GmailUser, GlobalConfig.AuthMode)
+	log.Printf("邮箱文件夹: %s", strings.Join(GlobalConfig.Folders, ", "))
+}
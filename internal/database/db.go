@@ -45,6 +45,8 @@ func InitDB() error {
 	var err error
 	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// 将唯一键冲突转换为 gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect database: %w", err)
@@ -54,6 +56,15 @@ func InitDB() error {
 	err = DB.AutoMigrate(
 		&models.Recipient{},
 		&models.ForwardingRule{},
+		&models.ForwardLog{},
+		&models.ForwardedMessage{},
+		&models.MailboxSyncState{},
+		&models.DestinationFilter{},
+		&models.TrustedSender{},
+		&models.OAuthToken{},
+		&models.Account{},
+		&models.OutboxJob{},
+		&models.MailActionGroup{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
@@ -66,4 +77,4 @@ func InitDB() error {
 // GetDB 获取数据库连接
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
@@ -0,0 +1,219 @@
+package gmail
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// gmailInboxLabel Gmail 收件箱系统标签，移除后邮件即被归档
+const gmailInboxLabel = `\Inbox`
+
+// 未找到 SPECIAL-USE 文件夹时使用的默认文件夹
+const (
+	defaultArchiveMailbox = "Archive"
+	gmailTrashMailbox     = "[Gmail]/Trash"
+)
+
+// uidExpunge UID EXPUNGE 命令（RFC 4315 UIDPLUS），只删除指定 UID 的邮件
+type uidExpunge struct {
+	seqset *imap.SeqSet
+}
+
+// Command 实现 imap.Commander 接口
+func (cmd *uidExpunge) Command() *imap.Command {
+	return &imap.Command{
+		Name:      "UID EXPUNGE",
+		Arguments: []interface{}{cmd.seqset},
+	}
+}
+
+// uidSet 创建只包含一个 UID 的序列集
+func uidSet(uid uint32) *imap.SeqSet {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	return seqset
+}
+
+// AddLabels 为当前选择邮箱中的邮件添加 Gmail 标签
+func (ic *IMAPClient) AddLabels(uid uint32, labels []string) error {
+	return ic.storeLabels(uid, imap.AddFlags, labels)
+}
+
+// RemoveLabels 移除当前选择邮箱中邮件的 Gmail 标签
+func (ic *IMAPClient) RemoveLabels(uid uint32, labels []string) error {
+	return ic.storeLabels(uid, imap.RemoveFlags, labels)
+}
+
+// storeLabels 使用 UID STORE ±X-GM-LABELS 修改邮件标签
+func (ic *IMAPClient) storeLabels(uid uint32, op imap.FlagsOp, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if !ic.supportsGmail() {
+		return errors.New("server does not support Gmail labels (X-GM-EXT-1)")
+	}
+
+	cmd, err := storeLabelsCommand(uid, op, labels)
+	if err != nil {
+		return fmt.Errorf("failed to update labels of UID %d: %w", uid, err)
+	}
+	status, err := ic.client.Execute(cmd, nil)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update labels of UID %d: %w", uid, err)
+	}
+	return nil
+}
+
+// storeLabelsCommand 构建 UID STORE <uid> +X-GM-LABELS/-X-GM-LABELS (...) 命令
+//
+// client.UidStore 会把字符串值转为原子，含空格的标签需要直接发送 STORE 命令以保留引号。
+// 标签名与文件夹名一样使用修改版 UTF-7 编码。
+func storeLabelsCommand(uid uint32, op imap.FlagsOp, labels []string) (imap.Commander, error) {
+	item := "+" + string(fetchLabels)
+	if op == imap.RemoveFlags {
+		item = "-" + string(fetchLabels)
+	}
+
+	values := make([]interface{}, 0, len(labels))
+	for _, label := range labels {
+		// 系统标签（如 \Inbox、\Important）以原子形式发送
+		if strings.HasPrefix(label, `\`) {
+			values = append(values, imap.RawString(label))
+			continue
+		}
+		encoded, err := utf7.Encoding.NewEncoder().String(label)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label %q: %w", label, err)
+		}
+		values = append(values, encoded)
+	}
+
+	return &commands.Uid{Cmd: &commands.Store{
+		SeqSet: uidSet(uid),
+		Item:   imap.StoreItem(item),
+		Value:  values,
+	}}, nil
+}
+
+// AddFlags 为当前选择邮箱中的邮件添加标记
+func (ic *IMAPClient) AddFlags(uid uint32, flags ...string) error {
+	values := make([]interface{}, 0, len(flags))
+	for _, flag := range flags {
+		values = append(values, flag)
+	}
+
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := ic.client.UidStore(uidSet(uid), item, values, nil); err != nil {
+		return fmt.Errorf("failed to add flags to UID %d: %w", uid, err)
+	}
+	return nil
+}
+
+// Move 将当前选择邮箱中的邮件移动到目标文件夹
+//
+// 服务器支持 MOVE 时直接移动，否则使用 COPY + \Deleted + EXPUNGE；
+// 支持 UIDPLUS 时使用 UID EXPUNGE 只删除这封邮件，不影响邮箱中其他已标记删除的邮件。
+func (ic *IMAPClient) Move(uid uint32, folder string) error {
+	seqset := uidSet(uid)
+
+	if ok, err := ic.client.Support("MOVE"); err == nil && ok {
+		if err := ic.client.UidMove(seqset, folder); err != nil {
+			return fmt.Errorf("failed to move UID %d to %s: %w", uid, folder, err)
+		}
+		return nil
+	}
+
+	if err := ic.client.UidCopy(seqset, folder); err != nil {
+		return fmt.Errorf("failed to copy UID %d to %s: %w", uid, folder, err)
+	}
+	return ic.expunge(uid)
+}
+
+// expunge 标记删除并永久删除当前选择邮箱中的邮件
+func (ic *IMAPClient) expunge(uid uint32) error {
+	seqset := uidSet(uid)
+
+	if err := ic.AddFlags(uid, imap.DeletedFlag); err != nil {
+		return err
+	}
+
+	if ok, err := ic.client.Support("UIDPLUS"); err == nil && ok {
+		status, err := ic.client.Execute(&uidExpunge{seqset: seqset}, nil)
+		if err == nil {
+			err = status.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to expunge UID %d: %w", uid, err)
+		}
+		return nil
+	}
+
+	log.Printf("Server does not support UIDPLUS, expunging all deleted messages")
+	if err := ic.client.Expunge(nil); err != nil {
+		return fmt.Errorf("failed to expunge UID %d: %w", uid, err)
+	}
+	return nil
+}
+
+// Archive 归档邮件：Gmail 移除收件箱标签；其他服务器移动到 folder，
+// folder 为空时使用 SPECIAL-USE 标记为 \Archive 的文件夹（没有时为 Archive）
+func (ic *IMAPClient) Archive(uid uint32, folder string) error {
+	if folder == "" && ic.supportsGmail() {
+		return ic.RemoveLabels(uid, []string{gmailInboxLabel})
+	}
+	if folder == "" {
+		folder = ic.specialUseMailbox(imap.ArchiveAttr, defaultArchiveMailbox)
+	}
+	return ic.Move(uid, folder)
+}
+
+// Delete 删除邮件：移动到 SPECIAL-USE 标记为 \Trash 的文件夹，找不到废纸篓时直接永久删除
+//
+// Gmail 默认设置下 EXPUNGE 只会移除当前标签（相当于归档），因此需要移动到废纸篓。
+func (ic *IMAPClient) Delete(uid uint32) error {
+	fallback := ""
+	if ic.supportsGmail() {
+		fallback = gmailTrashMailbox
+	}
+
+	trash := ic.specialUseMailbox(imap.TrashAttr, fallback)
+	if trash == "" {
+		return ic.expunge(uid)
+	}
+	return ic.Move(uid, trash)
+}
+
+// specialUseMailbox 查找带有指定 SPECIAL-USE 属性（RFC 6154）的文件夹，找不到时返回 fallback
+func (ic *IMAPClient) specialUseMailbox(attr, fallback string) string {
+	mailboxes := make(chan *imap.MailboxInfo, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- ic.client.List("", "*", mailboxes)
+	}()
+
+	found := ""
+	for info := range mailboxes {
+		for _, a := range info.Attributes {
+			if found == "" && strings.EqualFold(a, attr) {
+				found = info.Name
+			}
+		}
+	}
+	if err := <-done; err != nil {
+		log.Printf("Failed to list mailboxes: %v", err)
+	}
+
+	if found == "" {
+		return fallback
+	}
+	return found
+}
@@ -0,0 +1,55 @@
+package gmail
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestStoreLabelsCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     imap.FlagsOp
+		labels []string
+		want   string
+	}{
+		{
+			name:   "add",
+			op:     imap.AddFlags,
+			labels: []string{"Forwarded"},
+			want:   "A1 UID STORE 42 +X-GM-LABELS (\"Forwarded\")\r\n",
+		},
+		{
+			name:   "remove system label",
+			op:     imap.RemoveFlags,
+			labels: []string{`\Inbox`},
+			want:   "A1 UID STORE 42 -X-GM-LABELS (\\Inbox)\r\n",
+		},
+		{
+			name:   "quoted and modified utf-7 labels",
+			op:     imap.AddFlags,
+			labels: []string{"Needs Review", `\Important`, "已转发"},
+			want:   "A1 UID STORE 42 +X-GM-LABELS (\"Needs Review\" \\Important \"&XfKPbFPR-\")\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commander, err := storeLabelsCommand(42, tt.op, tt.labels)
+			if err != nil {
+				t.Fatalf("storeLabelsCommand: %v", err)
+			}
+			cmd := commander.Command()
+			cmd.Tag = "A1"
+
+			var buf bytes.Buffer
+			if err := cmd.WriteTo(imap.NewWriter(&buf)); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("command = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package gmail
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// DefaultIdleRestartInterval IDLE 命令的默认重发间隔，需小于服务器 29 分钟的超时时间
+const DefaultIdleRestartInterval = 25 * time.Minute
+
+// Idle 在指定邮箱上保持 IDLE 长连接，收到 EXISTS 通知时调用 onNewMail。
+// 每隔 restartInterval 重新发送 IDLE，避免被服务器断开。
+// stop 关闭时正常返回 nil，连接断开或命令失败时返回错误，由调用方负责重连。
+func (ic *IMAPClient) Idle(mailbox string, restartInterval time.Duration, stop <-chan struct{}, onNewMail func()) error {
+	if restartInterval <= 0 {
+		restartInterval = DefaultIdleRestartInterval
+	}
+
+	updates := make(chan client.Update, 16)
+	ic.client.Updates = updates
+
+	// 退出后继续消费更新，防止读协程阻塞导致 Logout 卡住
+	defer func() {
+		go func() {
+			for {
+				select {
+				case <-updates:
+				case <-ic.client.LoggedOut():
+					return
+				}
+			}
+		}()
+	}()
+
+	if _, err := ic.client.Select(mailbox, true); err != nil {
+		return fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+	log.Printf("Start IDLE on %s (restart every %s)", mailbox, restartInterval)
+
+	// IDLE 命令会持续到 restartInterval，不能使用单个命令的超时时间（IDLE 使用独立的连接）
+	ic.client.Timeout = 0
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ic.client.Idle(stop, &client.IdleOptions{LogoutTimeout: restartInterval})
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			if _, ok := update.(*client.MailboxUpdate); ok {
+				log.Printf("Received mailbox update on %s", mailbox)
+				onNewMail()
+			}
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("idle failed: %w", err)
+			}
+			return nil
+		case <-ic.client.LoggedOut():
+			return fmt.Errorf("connection closed while idling")
+		}
+	}
+}
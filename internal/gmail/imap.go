@@ -1,10 +1,16 @@
 package gmail
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/textproto"
 	"strings"
+	"time"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
@@ -13,81 +19,359 @@ import (
 
 // Email 邮件结构体
 type Email struct {
-	MessageID string
-	Subject   string
-	From      string
-	To        string
-	Body      string
-	HTML      string
+	UID         uint32
+	Mailbox     string   // 邮件所在的邮箱文件夹
+	Labels      []string // Gmail 标签（服务器支持 X-GM-EXT-1 时获取）
+	MessageID   string
+	Subject     string
+	From        string
+	FromAddress string // 不含显示名的发件人地址
+	To          string
+	ToAddresses []string // 全部收件人地址
+	CcAddresses []string // 全部抄送地址
+	Date        time.Time
+	Body        string
+	HTML        string
+
+	// Header 原始邮件头（键名已规范化，同名头按出现顺序排列）
+	Header textproto.MIMEHeader
+
+	Attachments []Attachment
+
+	// Raw 原始 RFC822 邮件内容，用于作为附件转发
+	Raw []byte
+}
+
+// Attachment 邮件附件（包括 HTML 中通过 cid: 引用的内嵌图片）
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string // 不含尖括号
+	Inline      bool
+	Data        []byte
 }
 
+// DefaultIMAPTimeout IMAP 连接和单个命令的默认超时时间
+const DefaultIMAPTimeout = 2 * time.Minute
+
 // IMAPClient IMAP 客户端
 type IMAPClient struct {
 	client   *client.Client
+	server   ServerConfig
 	username string
 	password string
+	tokens   TokenSource  // 不为空时使用 XOAUTH2 认证
+	filter   SearchFilter // 附加在每次搜索上的 Gmail 扩展条件
+	timeout  time.Duration
+
+	// stopCancel 取消 Connect 时注册的 context 取消回调
+	stopCancel func() bool
 }
 
-// NewIMAPClient 创建新的 IMAP 客户端
-func NewIMAPClient(username, password string) *IMAPClient {
+// NewIMAPClient 创建使用密码登录的 IMAP 客户端
+func NewIMAPClient(server ServerConfig, username, password string) *IMAPClient {
+	timeout := server.Timeout
+	if timeout <= 0 {
+		timeout = DefaultIMAPTimeout
+	}
 	return &IMAPClient{
+		server:   server,
 		username: username,
 		password: password,
+		timeout:  timeout,
 	}
 }
 
-// Connect 连接到 Gmail IMAP 服务器
-func (ic *IMAPClient) Connect() error {
-	// 连接到 Gmail IMAP 服务器
-	c, err := client.DialTLS("imap.gmail.com:993", nil)
+// NewOAuth2IMAPClient 创建使用 XOAUTH2 认证的 IMAP 客户端
+func NewOAuth2IMAPClient(server ServerConfig, username string, tokens TokenSource) *IMAPClient {
+	ic := NewIMAPClient(server, username, "")
+	ic.tokens = tokens
+	return ic
+}
+
+// Username 返回登录账户
+func (ic *IMAPClient) Username() string {
+	return ic.username
+}
+
+// Connect 连接并登录 IMAP 服务器
+//
+// ctx 控制整个连接的生命周期：ctx 取消时立即关闭连接，正在执行的命令返回错误。
+// 每个命令的执行时间不超过客户端的超时时间。
+func (ic *IMAPClient) Connect(ctx context.Context) error {
+	c, err := ic.dial(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to IMAP server: %w", err)
+		return fmt.Errorf("failed to connect to IMAP server %s: %w", ic.server.Addr(), err)
 	}
 	ic.client = c
+	ic.stopCancel = context.AfterFunc(ctx, func() {
+		c.Terminate()
+	})
 
 	// 登录
-	if err := c.Login(ic.username, ic.password); err != nil {
+	if err := ic.login(c); err != nil {
+		ic.stopCancel()
+		c.Logout()
 		return fmt.Errorf("failed to login: %w", err)
 	}
 
-	log.Println("Successfully connected to Gmail IMAP server")
+	log.Printf("Successfully connected to IMAP server %s (%s)", ic.server.Addr(), ic.server.TLSMode)
 	return nil
 }
 
-// FetchUnreadEmails 获取未读邮件
-func (ic *IMAPClient) FetchUnreadEmails() ([]*Email, error) {
-	// 选择收件箱
-	mbox, err := ic.client.Select("INBOX", false)
+// login 使用密码或 XOAUTH2 认证
+func (ic *IMAPClient) login(c *client.Client) error {
+	if ic.tokens == nil {
+		return c.Login(ic.username, ic.password)
+	}
+
+	token, err := ic.tokens.Token()
+	if err != nil {
+		return err
+	}
+	return c.Authenticate(&xoauth2Client{username: ic.username, accessToken: token.AccessToken})
+}
+
+// dial 按配置的加密方式建立 IMAP 连接，连接、TLS 握手和读取问候语都受超时时间和 ctx 限制
+func (ic *IMAPClient) dial(ctx context.Context) (*client.Client, error) {
+	var tlsConfig *tls.Config
+	if ic.server.TLSMode != TLSModePlain {
+		var err error
+		if tlsConfig, err = ic.server.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: ic.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", ic.server.Addr())
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(ic.timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 下面会将 conn 替换为 TLS 连接，回调使用原始连接，避免与赋值并发
+	rawConn := conn
+	stop := context.AfterFunc(ctx, func() {
+		rawConn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if ic.server.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := client.New(conn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select INBOX: %w", err)
+		conn.Close()
+		return nil, err
+	}
+	c.Timeout = ic.timeout
+
+	if ic.server.TLSMode == TLSModeStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// SyncState IMAP 增量同步状态
+type SyncState struct {
+	UIDValidity  uint32
+	LastUID      uint32
+	LastSyncedAt time.Time
+}
+
+// FetchUnreadEmails 获取指定邮箱中的未读邮件
+func (ic *IMAPClient) FetchUnreadEmails(mailbox string) ([]*Email, error) {
+	mbox, err := ic.client.Select(mailbox, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", mailbox, err)
 	}
 	log.Printf("Mailbox contains %d messages", mbox.Messages)
 
 	// 搜索未读邮件
 	criteria := imap.NewSearchCriteria()
 	criteria.WithoutFlags = []string{imap.SeenFlag}
-	ids, err := ic.client.Search(criteria)
+	uids, err := ic.uidSearch(criteria)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search emails: %w", err)
 	}
 
-	if len(ids) == 0 {
+	if len(uids) == 0 {
 		log.Println("No unread emails found")
 		return nil, nil
 	}
 
-	log.Printf("Found %d unread emails", len(ids))
+	log.Printf("Found %d unread emails", len(uids))
+	return ic.fetchByUIDs(uids)
+}
+
+// FetchNewEmails 基于 UID 增量获取邮件，返回 UID 大于 state.LastUID 的邮件，
+// 以及全部邮件处理完成后应保存的同步状态。
+//
+// 如果 UIDVALIDITY 与保存的状态不一致（或尚无同步记录），旧的 UID 已失去意义，
+// 此时执行全量重新同步：有上次同步时间时按 SINCE 搜索该日期之后的邮件，
+// 否则退回到搜索未读邮件。重新同步可能返回已处理过的邮件，由调用方去重。
+func (ic *IMAPClient) FetchNewEmails(mailbox string, state SyncState) ([]*Email, SyncState, error) {
+	mbox, err := ic.client.Select(mailbox, false)
+	if err != nil {
+		return nil, state, fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+	log.Printf("Mailbox %s contains %d messages (UIDVALIDITY %d, UIDNEXT %d)",
+		mailbox, mbox.Messages, mbox.UidValidity, mbox.UidNext)
+
+	next := SyncState{
+		UIDValidity:  mbox.UidValidity,
+		LastUID:      state.LastUID,
+		LastSyncedAt: time.Now(),
+	}
+
+	criteria := imap.NewSearchCriteria()
+	if state.UIDValidity != mbox.UidValidity {
+		log.Printf("UIDVALIDITY of %s changed (%d -> %d), performing full resync",
+			mailbox, state.UIDValidity, mbox.UidValidity)
+		next.LastUID = 0
+		if !state.LastSyncedAt.IsZero() {
+			criteria.Since = state.LastSyncedAt
+		} else {
+			criteria.WithoutFlags = []string{imap.SeenFlag}
+		}
+	} else {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(state.LastUID+1, 0)
+	}
+
+	found, err := ic.uidSearch(criteria)
+	if err != nil {
+		return nil, state, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	// "n:*" 在没有新邮件时也会返回当前最大 UID，需要再过滤一次
+	uids := make([]uint32, 0, len(found))
+	for _, uid := range found {
+		if uid > next.LastUID {
+			uids = append(uids, uid)
+		}
+	}
+
+	// 重新同步时以邮箱当前最大的 UID 作为新的基线，避免下次再次扫描旧邮件
+	if next.LastUID == 0 {
+		highest, err := ic.highestUID(mbox)
+		if err != nil {
+			return nil, state, fmt.Errorf("failed to determine highest UID of %s: %w", mailbox, err)
+		}
+		next.LastUID = highest
+	}
+	for _, uid := range uids {
+		if uid > next.LastUID {
+			next.LastUID = uid
+		}
+	}
+
+	if len(uids) == 0 {
+		log.Printf("No new emails found in %s", mailbox)
+		return nil, next, nil
+	}
+
+	log.Printf("Found %d new emails in %s", len(uids), mailbox)
+	emails, err := ic.fetchByUIDs(uids)
+	if err != nil {
+		return nil, state, err
+	}
+	return emails, next, nil
+}
+
+// highestUID 返回邮箱中最大的 UID，邮箱为空时为 0；服务器未返回 UIDNEXT 时搜索 "UID *" 获取
+func (ic *IMAPClient) highestUID(mbox *imap.MailboxStatus) (uint32, error) {
+	if mbox.UidNext > 0 {
+		return mbox.UidNext - 1, nil
+	}
+	if mbox.Messages == 0 {
+		return 0, nil
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddNum(0) // 0 表示 "*"，即最大的 UID
+	uids, err := ic.client.UidSearch(criteria)
+	if err != nil {
+		return 0, err
+	}
+	var highest uint32
+	for _, uid := range uids {
+		if uid > highest {
+			highest = uid
+		}
+	}
+	return highest, nil
+}
+
+// SearchByDate 以只读方式选择邮箱，按邮件到达日期搜索 UID（IMAP SINCE/BEFORE），零值表示不限制
+//
+// 只读选择保证获取邮件时不会设置 \Seen 标记，用于回溯处理历史邮件。
+func (ic *IMAPClient) SearchByDate(mailbox string, since, before time.Time) ([]uint32, error) {
+	if _, err := ic.client.Select(mailbox, true); err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	criteria.Before = before
+	uids, err := ic.uidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	log.Printf("Found %d emails in %s (since %s, before %s)", len(uids), mailbox,
+		formatSearchDate(since), formatSearchDate(before))
+	return uids, nil
+}
+
+// formatSearchDate 格式化搜索日期用于日志，零值显示为 "-"
+func formatSearchDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
+
+// FetchByUIDs 按 UID 获取并解析当前选择邮箱中的邮件
+func (ic *IMAPClient) FetchByUIDs(uids []uint32) ([]*Email, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	return ic.fetchByUIDs(uids)
+}
 
-	// 创建序列集
+// fetchByUIDs 按 UID 获取并解析邮件
+func (ic *IMAPClient) fetchByUIDs(uids []uint32) ([]*Email, error) {
 	seqset := new(imap.SeqSet)
-	seqset.AddNum(ids...)
+	seqset.AddNum(uids...)
+
+	// 获取邮件内容，Gmail 同时获取邮件的标签
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, imap.FetchRFC822}
+	if ic.supportsGmail() {
+		items = append(items, fetchLabels)
+	}
+	var mailbox string
+	if status := ic.client.Mailbox(); status != nil {
+		mailbox = status.Name
+	}
 
-	// 获取邮件内容
-	messages := make(chan *imap.Message, len(ids))
+	messages := make(chan *imap.Message, len(uids))
 	done := make(chan error, 1)
 
 	go func() {
-		done <- ic.client.Fetch(seqset, []imap.FetchItem{imap.FetchEnvelope, imap.FetchRFC822}, messages)
+		done <- ic.client.UidFetch(seqset, items, messages)
 	}()
 
 	emails := []*Email{}
@@ -97,6 +381,8 @@ func (ic *IMAPClient) FetchUnreadEmails() ([]*Email, error) {
 			log.Printf("Failed to parse message: %v", err)
 			continue
 		}
+		email.Mailbox = mailbox
+		email.Labels = parseLabels(msg)
 		emails = append(emails, email)
 	}
 
@@ -109,88 +395,185 @@ func (ic *IMAPClient) FetchUnreadEmails() ([]*Email, error) {
 
 // parseMessage 解析邮件消息
 func (ic *IMAPClient) parseMessage(msg *imap.Message) (*Email, error) {
-	email := &Email{}
+	email := &Email{UID: msg.Uid}
 
 	// 获取邮件头信息
 	if msg.Envelope != nil {
 		email.MessageID = msg.Envelope.MessageId
 		email.Subject = msg.Envelope.Subject
+		email.Date = msg.Envelope.Date
 		if len(msg.Envelope.From) > 0 {
 			email.From = fmt.Sprintf("%s <%s>", msg.Envelope.From[0].PersonalName, msg.Envelope.From[0].Address())
+			email.FromAddress = msg.Envelope.From[0].Address()
 		}
 		if len(msg.Envelope.To) > 0 {
 			email.To = fmt.Sprintf("%s <%s>", msg.Envelope.To[0].PersonalName, msg.Envelope.To[0].Address())
 		}
+		for _, addr := range msg.Envelope.To {
+			email.ToAddresses = append(email.ToAddresses, addr.Address())
+		}
+		for _, addr := range msg.Envelope.Cc {
+			email.CcAddresses = append(email.CcAddresses, addr.Address())
+		}
 	}
 
 	// 获取邮件正文
 	for _, value := range msg.Body {
-		mr, err := mail.CreateReader(value)
+		raw, err := io.ReadAll(value)
 		if err != nil {
 			continue
 		}
+		if _, err := parseContent(email, raw); err != nil {
+			log.Printf("Failed to parse message body: %v", err)
+		}
+	}
 
-		// 读取邮件各部分
-		for {
-			p, err := mr.NextPart()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				continue
-			}
+	return email, nil
+}
 
-			switch h := p.Header.(type) {
-			case *mail.InlineHeader:
-				// 读取内联内容
-				b, _ := io.ReadAll(p.Body)
-				contentType, _, _ := h.ContentType()
-				if strings.HasPrefix(contentType, "text/plain") {
-					email.Body = string(b)
-				} else if strings.HasPrefix(contentType, "text/html") {
-					email.HTML = string(b)
-				}
-			}
+// ParseEmail 解析原始 RFC822 邮件，邮件头信息取自邮件本身而不是 IMAP ENVELOPE
+func ParseEmail(raw []byte) (*Email, error) {
+	email := &Email{}
+	mr, err := parseContent(email, raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	h := mr.Header
+	email.MessageID, _ = h.MessageID()
+	if email.MessageID != "" {
+		email.MessageID = "<" + email.MessageID + ">"
+	}
+	email.Subject, _ = h.Subject()
+	email.Date, _ = h.Date()
+	if from, err := h.AddressList("From"); err == nil && len(from) > 0 {
+		email.From = fmt.Sprintf("%s <%s>", from[0].Name, from[0].Address)
+		email.FromAddress = from[0].Address
+	}
+	if to, err := h.AddressList("To"); err == nil {
+		if len(to) > 0 {
+			email.To = fmt.Sprintf("%s <%s>", to[0].Name, to[0].Address)
+		}
+		for _, addr := range to {
+			email.ToAddresses = append(email.ToAddresses, addr.Address)
+		}
+	}
+	if cc, err := h.AddressList("Cc"); err == nil {
+		for _, addr := range cc {
+			email.CcAddresses = append(email.CcAddresses, addr.Address)
 		}
 	}
 
 	return email, nil
 }
 
-// MarkAsRead 标记邮件为已读
-func (ic *IMAPClient) MarkAsRead(messageID string) error {
-	// 搜索特定消息ID的邮件
-	criteria := imap.NewSearchCriteria()
-	criteria.Header.Add("Message-ID", messageID)
-	ids, err := ic.client.Search(criteria)
+// parseContent 解析原始邮件的邮件头、正文和附件并填充到 email
+func parseContent(email *Email, raw []byte) (*mail.Reader, error) {
+	email.Raw = raw
+
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
 	if err != nil {
-		return fmt.Errorf("failed to search email by message ID: %w", err)
+		return nil, err
 	}
 
-	if len(ids) == 0 {
-		return fmt.Errorf("email not found with message ID: %s", messageID)
+	// 保存邮件头
+	email.Header = make(textproto.MIMEHeader)
+	fields := mr.Header.Fields()
+	for fields.Next() {
+		email.Header.Add(fields.Key(), fields.Value())
+	}
+
+	// 读取邮件各部分
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		switch h := p.Header.(type) {
+		case *mail.InlineHeader:
+			// 读取内联内容
+			b, _ := io.ReadAll(p.Body)
+			contentType, params, _ := h.ContentType()
+			if strings.HasPrefix(contentType, "text/plain") && email.Body == "" {
+				email.Body = string(b)
+			} else if strings.HasPrefix(contentType, "text/html") && email.HTML == "" {
+				email.HTML = string(b)
+			} else {
+				// 内嵌图片等非文本内联部分；多余的文本部分（如 text/calendar）按普通附件保留
+				email.Attachments = append(email.Attachments, Attachment{
+					Filename:    params["name"],
+					ContentType: contentType,
+					ContentID:   contentID(h.Get("Content-Id")),
+					Inline:      !strings.HasPrefix(contentType, "text/"),
+					Data:        b,
+				})
+			}
+		case *mail.AttachmentHeader:
+			// 读取附件
+			b, err := io.ReadAll(p.Body)
+			if err != nil {
+				log.Printf("Failed to read attachment: %v", err)
+				continue
+			}
+			filename, _ := h.Filename()
+			contentType, _, _ := h.ContentType()
+			email.Attachments = append(email.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				ContentID:   contentID(h.Get("Content-Id")),
+				Data:        b,
+			})
+		}
+	}
+
+	return mr, nil
+}
+
+// contentID 去掉 Content-ID 头两侧的尖括号
+func contentID(value string) string {
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// MarkAsRead 使用一条 UID STORE 命令将当前选择邮箱中的多封邮件标记为已读
+func (ic *IMAPClient) MarkAsRead(uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
 	}
 
-	// 创建序列集
 	seqset := new(imap.SeqSet)
-	seqset.AddNum(ids...)
+	seqset.AddNum(uids...)
 
 	// 添加已读标记
 	item := imap.FormatFlagsOp(imap.AddFlags, true)
 	flags := []interface{}{imap.SeenFlag}
-	err = ic.client.Store(seqset, item, flags, nil)
-	if err != nil {
-		return fmt.Errorf("failed to mark email as read: %w", err)
+	if err := ic.client.UidStore(seqset, item, flags, nil); err != nil {
+		return fmt.Errorf("failed to mark emails as read: %w", err)
 	}
 
-	log.Printf("Marked email %s as read", messageID)
+	log.Printf("Marked %d emails as read", len(uids))
+	return nil
+}
+
+// SelectMailbox 以读写方式选择邮箱，之后可以对其中的邮件执行标签、移动等操作
+func (ic *IMAPClient) SelectMailbox(mailbox string) error {
+	if _, err := ic.client.Select(mailbox, false); err != nil {
+		return fmt.Errorf("failed to select mailbox %s: %w", mailbox, err)
+	}
 	return nil
 }
 
 // Disconnect 断开连接
 func (ic *IMAPClient) Disconnect() error {
+	if ic.stopCancel != nil {
+		ic.stopCancel()
+		ic.stopCancel = nil
+	}
 	if ic.client != nil {
 		return ic.client.Logout()
 	}
 	return nil
-}
\ No newline at end of file
+}
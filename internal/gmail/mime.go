@@ -0,0 +1,116 @@
+package gmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// mimePart 构建转发邮件时使用的 MIME 部分
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// textPart 创建 UTF-8 文本部分
+func textPart(contentType, body string) mimePart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	return mimePart{header: header, body: []byte(body)}
+}
+
+// attachmentPart 创建 base64 编码的附件部分，inline 为 true 时作为内嵌资源
+func attachmentPart(att Attachment, inline bool) mimePart {
+	contentType := att.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := make(textproto.MIMEHeader)
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	if att.Filename != "" {
+		header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"name": att.Filename}))
+		header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+	} else {
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Disposition", disposition)
+	}
+	if att.ContentID != "" {
+		header.Set("Content-ID", "<"+att.ContentID+">")
+	}
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	return mimePart{header: header, body: encodeBase64Lines(att.Data)}
+}
+
+// rfc822Part 创建 message/rfc822 附件部分，原始邮件内容不做任何修改
+//
+// RFC 2046 不允许对 message/rfc822 使用 base64 编码，因此按 8bit 原样写入。
+func rfc822Part(raw []byte) mimePart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "message/rfc822")
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "original.eml"}))
+	header.Set("Content-Transfer-Encoding", "8bit")
+	return mimePart{header: header, body: raw}
+}
+
+// multipartPart 将多个部分组合为 multipart/<subtype>
+//
+// 写入目标是内存缓冲区，不会产生写入错误，因此忽略 multipart.Writer 的返回值。
+func multipartPart(subtype string, parts []mimePart) mimePart {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	for _, p := range parts {
+		pw, _ := w.CreatePart(p.header)
+		pw.Write(p.body)
+	}
+	w.Close()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", fmt.Sprintf("multipart/%s; boundary=%q", subtype, w.Boundary()))
+	return mimePart{header: header, body: buf.Bytes()}
+}
+
+// writeHeader 按固定顺序写出 MIME 头
+func (p mimePart) writeHeader(b *strings.Builder) {
+	keys := make([]string, 0, len(p.header))
+	for k := range p.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range p.header[k] {
+			b.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+		}
+	}
+}
+
+// encodeBase64Lines base64 编码并按 76 字符换行（RFC 2045）
+func encodeBase64Lines(data []byte) []byte {
+	const lineLen = 76
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var buf bytes.Buffer
+	for len(encoded) > lineLen {
+		buf.WriteString(encoded[:lineLen])
+		buf.WriteString("\r\n")
+		encoded = encoded[lineLen:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// referencedByHTML 判断内嵌资源是否在 HTML 正文中通过 cid: 引用
+func referencedByHTML(html string, att Attachment) bool {
+	return att.ContentID != "" && strings.Contains(html, "cid:"+att.ContentID)
+}
@@ -0,0 +1,195 @@
+package gmail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenURL Google OAuth2 令牌端点
+const DefaultTokenURL = "https://oauth2.googleapis.com/token"
+
+// tokenExpiryDelta 访问令牌提前过期的时间，避免使用即将过期的令牌
+const tokenExpiryDelta = time.Minute
+
+// Token OAuth2 令牌
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time // 零值表示未知，视为已过期
+}
+
+// Valid 检查访问令牌是否仍然可用
+func (t *Token) Valid() bool {
+	return t != nil && t.AccessToken != "" && time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// TokenSource 提供有效的 OAuth2 访问令牌
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenStore 持久化 OAuth2 令牌，刷新令牌轮换后需要保存新的刷新令牌
+type TokenStore interface {
+	LoadToken() (*Token, error)
+	SaveToken(token *Token) error
+}
+
+// RefreshTokenSource 使用刷新令牌自动获取访问令牌的 TokenSource，可在多个客户端间共享
+type RefreshTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	store        TokenStore
+	httpClient   *http.Client
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewRefreshTokenSource 创建刷新令牌 TokenSource，tokenURL 可以指向本地模拟的令牌端点用于测试
+func NewRefreshTokenSource(tokenURL, clientID, clientSecret string, store TokenStore) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		store:        store,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Token 返回有效的访问令牌，过期时使用刷新令牌重新获取并保存
+func (ts *RefreshTokenSource) Token() (*Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token == nil {
+		token, err := ts.store.LoadToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OAuth2 token: %w", err)
+		}
+		ts.token = token
+	}
+	if ts.token.Valid() {
+		return ts.token, nil
+	}
+	if ts.token == nil || ts.token.RefreshToken == "" {
+		return nil, errors.New("no OAuth2 refresh token available")
+	}
+
+	token, err := ts.refresh(ts.token.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	ts.token = token
+
+	if err := ts.store.SaveToken(token); err != nil {
+		log.Printf("Failed to save OAuth2 token: %v", err)
+	}
+	return token, nil
+}
+
+// tokenResponse 令牌端点响应
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// refresh 使用刷新令牌向令牌端点请求新的访问令牌
+func (ts *RefreshTokenSource) refresh(refreshToken string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {ts.clientID},
+		"client_secret": {ts.clientSecret},
+	}
+
+	resp, err := ts.httpClient.PostForm(ts.tokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode OAuth2 token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("OAuth2 token refresh failed (HTTP %d): %s %s",
+			resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("OAuth2 token response has no access_token")
+	}
+
+	token := &Token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: refreshToken,
+		Expiry:       time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}
+	// 令牌端点可能轮换刷新令牌
+	if body.RefreshToken != "" {
+		token.RefreshToken = body.RefreshToken
+	}
+
+	log.Printf("OAuth2 access token refreshed, expires at %s", token.Expiry.Format(time.RFC3339))
+	return token, nil
+}
+
+// xoauth2Response 构建 XOAUTH2 初始响应
+func xoauth2Response(username, accessToken string) []byte {
+	return []byte("user=" + username + "\x01auth=Bearer " + accessToken + "\x01\x01")
+}
+
+// xoauth2Client IMAP XOAUTH2 SASL 客户端（实现 go-sasl 的 Client 接口）
+type xoauth2Client struct {
+	username    string
+	accessToken string
+}
+
+// Start 返回机制名称和初始响应
+func (a *xoauth2Client) Start() (string, []byte, error) {
+	return "XOAUTH2", xoauth2Response(a.username, a.accessToken), nil
+}
+
+// Next 认证失败时服务器返回 JSON 错误详情，回复空响应以结束认证并获得错误结果
+func (a *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// xoauth2Auth SMTP XOAUTH2 认证（实现 smtp.Auth 接口）
+type xoauth2Auth struct {
+	username    string
+	accessToken string
+}
+
+// Start 返回机制名称和初始响应
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "XOAUTH2", xoauth2Response(a.username, a.accessToken), nil
+}
+
+// Next 认证失败时服务器返回 334 和 JSON 错误详情，回复空响应以获得最终错误
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// isLocalhost 检查服务器是否为本机，允许本地测试服务器使用明文连接
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1" || strings.HasSuffix(host, ".localhost")
+}
@@ -0,0 +1,237 @@
+package gmail
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryTokenStore 内存中的 TokenStore
+type memoryTokenStore struct {
+	token   *Token
+	loadErr error
+	saved   []*Token
+}
+
+func (s *memoryTokenStore) LoadToken() (*Token, error) {
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	return s.token, nil
+}
+
+func (s *memoryTokenStore) SaveToken(token *Token) error {
+	s.saved = append(s.saved, token)
+	s.token = token
+	return nil
+}
+
+// fakeTokenEndpoint 模拟令牌端点，handler 为空时返回固定的访问令牌
+func fakeTokenEndpoint(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if handler != nil {
+			handler(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-" + r.PostForm.Get("refresh_token"),
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRefreshTokenSourceRefreshesAndCaches(t *testing.T) {
+	var form map[string]string
+	srv, calls := fakeTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		form = map[string]string{
+			"grant_type":    r.PostForm.Get("grant_type"),
+			"refresh_token": r.PostForm.Get("refresh_token"),
+			"client_id":     r.PostForm.Get("client_id"),
+			"client_secret": r.PostForm.Get("client_secret"),
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-1",
+			"expires_in":   3600,
+		})
+	})
+	store := &memoryTokenStore{token: &Token{RefreshToken: "refresh-1"}}
+	ts := NewRefreshTokenSource(srv.URL, "client", "secret", store)
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" {
+		t.Fatalf("token = %+v", token)
+	}
+	if d := time.Until(token.Expiry); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiry in %v, want about 1h", d)
+	}
+	want := map[string]string{"grant_type": "refresh_token", "refresh_token": "refresh-1", "client_id": "client", "client_secret": "secret"}
+	for k, v := range want {
+		if form[k] != v {
+			t.Errorf("form[%s] = %q, want %q", k, form[k], v)
+		}
+	}
+	if len(store.saved) != 1 {
+		t.Errorf("saved %d tokens, want 1", len(store.saved))
+	}
+
+	// 令牌仍然有效时不再请求令牌端点
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1", n)
+	}
+}
+
+func TestRefreshTokenSourceUsesStoredToken(t *testing.T) {
+	srv, calls := fakeTokenEndpoint(t, nil)
+	store := &memoryTokenStore{token: &Token{AccessToken: "stored", RefreshToken: "refresh-1", Expiry: time.Now().Add(time.Hour)}}
+	ts := NewRefreshTokenSource(srv.URL, "client", "secret", store)
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token.AccessToken != "stored" {
+		t.Errorf("access token = %q, want stored", token.AccessToken)
+	}
+	if n := atomic.LoadInt32(calls); n != 0 {
+		t.Errorf("token endpoint called %d times, want 0", n)
+	}
+}
+
+func TestRefreshTokenSourceExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiry      time.Time
+		wantRefresh bool
+	}{
+		{name: "valid", expiry: time.Now().Add(10 * time.Minute)},
+		{name: "expired", expiry: time.Now().Add(-time.Minute), wantRefresh: true},
+		{name: "within skew", expiry: time.Now().Add(tokenExpiryDelta / 2), wantRefresh: true},
+		{name: "unknown expiry", wantRefresh: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := fakeTokenEndpoint(t, nil)
+			store := &memoryTokenStore{token: &Token{AccessToken: "old", RefreshToken: "refresh-1", Expiry: tt.expiry}}
+			ts := NewRefreshTokenSource(srv.URL, "client", "secret", store)
+
+			token, err := ts.Token()
+			if err != nil {
+				t.Fatalf("Token: %v", err)
+			}
+			refreshed := atomic.LoadInt32(calls) == 1
+			if refreshed != tt.wantRefresh {
+				t.Fatalf("refreshed = %v, want %v", refreshed, tt.wantRefresh)
+			}
+			if tt.wantRefresh && token.AccessToken != "access-refresh-1" {
+				t.Errorf("access token = %q, want access-refresh-1", token.AccessToken)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenSourceRotatesRefreshToken(t *testing.T) {
+	srv, _ := fakeTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-2",
+			"refresh_token": "refresh-2",
+			"expires_in":    3600,
+		})
+	})
+	store := &memoryTokenStore{token: &Token{RefreshToken: "refresh-1"}}
+	ts := NewRefreshTokenSource(srv.URL, "client", "secret", store)
+
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if store.token.RefreshToken != "refresh-2" {
+		t.Errorf("stored refresh token = %q, want refresh-2", store.token.RefreshToken)
+	}
+}
+
+func TestRefreshTokenSourceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *memoryTokenStore
+		handler func(w http.ResponseWriter, r *http.Request)
+		wantErr string
+	}{
+		{
+			name:    "load error",
+			store:   &memoryTokenStore{loadErr: errors.New("db down")},
+			wantErr: "db down",
+		},
+		{
+			name:    "no refresh token",
+			store:   &memoryTokenStore{token: &Token{}},
+			wantErr: "no OAuth2 refresh token",
+		},
+		{
+			name:  "invalid grant",
+			store: &memoryTokenStore{token: &Token{RefreshToken: "revoked"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":             "invalid_grant",
+					"error_description": "Token has been expired or revoked.",
+				})
+			},
+			wantErr: "invalid_grant",
+		},
+		{
+			name:  "malformed response",
+			store: &memoryTokenStore{token: &Token{RefreshToken: "refresh-1"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte("<html>bad gateway</html>"))
+			},
+			wantErr: "HTTP 502",
+		},
+		{
+			name:  "missing access token",
+			store: &memoryTokenStore{token: &Token{RefreshToken: "refresh-1"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"expires_in": 3600})
+			},
+			wantErr: "no access_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := fakeTokenEndpoint(t, tt.handler)
+			ts := NewRefreshTokenSource(srv.URL, "client", "secret", tt.store)
+
+			token, err := ts.Token()
+			if err == nil {
+				t.Fatalf("Token = %+v, want error", token)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if len(tt.store.saved) != 0 {
+				t.Errorf("saved %d tokens after failure, want 0", len(tt.store.saved))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package gmail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// gmailExtension Gmail IMAP 扩展的能力标识（X-GM-LABELS、X-GM-RAW 等）
+const gmailExtension = "X-GM-EXT-1"
+
+// fetchLabels 获取 Gmail 标签的 FETCH 数据项
+const fetchLabels imap.FetchItem = "X-GM-LABELS"
+
+// SearchFilter Gmail 搜索扩展条件，附加在每次搜索上
+//
+// Labels 要求邮件同时带有所有标签（X-GM-LABELS），Raw 为 Gmail 网页搜索语法（X-GM-RAW），
+// 例如 "from:alerts@example.com newer_than:7d"。零值表示不附加条件。
+type SearchFilter struct {
+	Labels []string
+	Raw    string
+}
+
+// IsZero 检查是否没有设置任何条件
+func (f SearchFilter) IsZero() bool {
+	return len(f.Labels) == 0 && strings.TrimSpace(f.Raw) == ""
+}
+
+// format 将条件格式化为 SEARCH 命令参数
+func (f SearchFilter) format() []interface{} {
+	var args []interface{}
+	for _, label := range f.Labels {
+		args = append(args, imap.RawString("X-GM-LABELS"), label)
+	}
+	if raw := strings.TrimSpace(f.Raw); raw != "" {
+		args = append(args, imap.RawString("X-GM-RAW"), raw)
+	}
+	return args
+}
+
+// gmailSearch 附加 Gmail 扩展条件的 SEARCH 命令
+type gmailSearch struct {
+	criteria *imap.SearchCriteria
+	filter   SearchFilter
+}
+
+// Command 实现 imap.Commander 接口
+func (cmd *gmailSearch) Command() *imap.Command {
+	args := []interface{}{imap.RawString("CHARSET"), imap.RawString("UTF-8")}
+	args = append(args, cmd.criteria.Format()...)
+	args = append(args, cmd.filter.format()...)
+
+	return &imap.Command{
+		Name:      "SEARCH",
+		Arguments: args,
+	}
+}
+
+// SetSearchFilter 设置附加在每次搜索上的 Gmail 扩展条件，服务器不支持 X-GM-EXT-1 时搜索会返回错误
+func (ic *IMAPClient) SetSearchFilter(filter SearchFilter) {
+	ic.filter = filter
+}
+
+// supportsGmail 检查服务器是否支持 Gmail IMAP 扩展
+func (ic *IMAPClient) supportsGmail() bool {
+	ok, err := ic.client.Support(gmailExtension)
+	return err == nil && ok
+}
+
+// uidSearch 在当前选择的邮箱中按 UID 搜索，设置了 Gmail 扩展条件时一并发送
+func (ic *IMAPClient) uidSearch(criteria *imap.SearchCriteria) ([]uint32, error) {
+	if ic.filter.IsZero() {
+		return ic.client.UidSearch(criteria)
+	}
+	if !ic.supportsGmail() {
+		return nil, errors.New("server does not support Gmail search extensions (X-GM-EXT-1)")
+	}
+
+	res := new(responses.Search)
+	status, err := ic.client.Execute(&commands.Uid{Cmd: &gmailSearch{criteria: criteria, filter: ic.filter}}, res)
+	if err != nil {
+		return nil, err
+	}
+	if err := status.Err(); err != nil {
+		return nil, fmt.Errorf("gmail search failed: %w", err)
+	}
+	return res.Ids, nil
+}
+
+// parseLabels 解析 FETCH 响应中的 X-GM-LABELS 数据项，标签名为修改版 UTF-7 编码，解码失败时保留原值
+func parseLabels(msg *imap.Message) []string {
+	value, ok := msg.Items[fetchLabels]
+	if !ok {
+		return nil
+	}
+	fields, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	labels := make([]string, 0, len(fields))
+	for _, field := range fields {
+		label, err := imap.ParseString(field)
+		if err != nil {
+			continue
+		}
+		if decoded, err := utf7.Encoding.NewDecoder().String(label); err == nil {
+			label = decoded
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
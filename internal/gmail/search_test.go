@@ -0,0 +1,46 @@
+package gmail
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []interface{}
+		want   []string
+	}{
+		{
+			name:   "ascii and system labels",
+			fields: []interface{}{"Forwarded", imap.RawString(`\Inbox`)},
+			want:   []string{"Forwarded", `\Inbox`},
+		},
+		{
+			name:   "modified utf-7",
+			fields: []interface{}{"&XfKPbFPR-", "Work/&ZeVnLIqe-"},
+			want:   []string{"已转发", "Work/日本語"},
+		},
+		{
+			name:   "ampersand",
+			fields: []interface{}{"R&-D"},
+			want:   []string{"R&D"},
+		},
+		{
+			name:   "invalid encoding kept as is",
+			fields: []interface{}{"&Jjo!"},
+			want:   []string{"&Jjo!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &imap.Message{Items: map[imap.FetchItem]interface{}{fetchLabels: tt.fields}}
+			if got := parseLabels(msg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLabels = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gmail
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// TLSMode 与邮件服务器的连接加密方式
+type TLSMode string
+
+const (
+	// TLSModeImplicit 连接建立即使用 TLS（IMAPS 993 / SMTPS 465）
+	TLSModeImplicit TLSMode = "tls"
+	// TLSModeStartTLS 明文连接后通过 STARTTLS 升级，服务器不支持时报错
+	TLSModeStartTLS TLSMode = "starttls"
+	// TLSModePlain 不加密，仅用于本地测试服务器
+	TLSModePlain TLSMode = "plain"
+)
+
+// ValidTLSMode 检查加密方式是否有效
+func ValidTLSMode(mode string) bool {
+	switch TLSMode(mode) {
+	case TLSModeImplicit, TLSModeStartTLS, TLSModePlain:
+		return true
+	}
+	return false
+}
+
+// ServerConfig 邮件服务器连接配置
+type ServerConfig struct {
+	Host               string
+	Port               string
+	TLSMode            TLSMode
+	CAFile             string // PEM 格式的 CA 证书，为空时使用系统证书
+	InsecureSkipVerify bool   // 跳过证书校验，仅用于测试
+
+	// Timeout 连接和单个命令（SMTP 为每封邮件）的超时时间，为 0 时使用客户端默认值
+	Timeout time.Duration
+}
+
+// DefaultIMAPServer Gmail IMAP 服务器
+var DefaultIMAPServer = ServerConfig{Host: "imap.gmail.com", Port: "993", TLSMode: TLSModeImplicit}
+
+// DefaultSMTPServer Gmail SMTP 服务器
+var DefaultSMTPServer = ServerConfig{Host: "smtp.gmail.com", Port: "587", TLSMode: TLSModeStartTLS}
+
+// Addr 返回 host:port 形式的服务器地址
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// tlsConfig 根据配置构建 TLS 配置
+func (s ServerConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         s.Host,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+	if s.CAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(s.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", s.CAFile)
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
@@ -1,88 +1,318 @@
 package gmail
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"net/smtp"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// DefaultSMTPTimeout SMTP 连接和每封邮件发送的默认超时时间
+	DefaultSMTPTimeout = 30 * time.Second
+	// SMTPSessionIdleTimeout 会话空闲超过该时长后不再复用（服务器通常会断开长时间空闲的连接）
+	SMTPSessionIdleTimeout = 2 * time.Minute
+	// smtpSessionMaxMessages 单个会话最多发送的邮件数，达到后重新建立会话
+	smtpSessionMaxMessages = 100
+)
+
 // SMTPClient SMTP 客户端
+//
+// 客户端保持一个已认证的 SMTP 会话，连续发送的邮件复用同一会话（每封邮件前发送 RSET），
+// 避免每封邮件都重新建立 TCP+TLS 连接和认证；会话被服务器断开时自动重新连接。
 type SMTPClient struct {
-	host        string
-	port        string
-	username    string
-	password    string
-	maxRetries  int
-	retryDelay time.Duration
-	timeout     time.Duration
+	server   ServerConfig
+	username string
+	password string
+	tokens   TokenSource   // 不为空时使用 XOAUTH2 认证
+	timeout  time.Duration // 连接和每封邮件发送的超时时间
+
+	mu       sync.Mutex
+	session  *smtp.Client
+	conn     net.Conn // 会话的底层连接，用于设置读写截止时间
+	lastUsed time.Time
+	sent     int // 当前会话已发送的邮件数
 }
 
-// NewSMTPClient 创建新的 SMTP 客户端
-func NewSMTPClient(username, password string) *SMTPClient {
+// ForwardMode 转发方式
+type ForwardMode string
+
+const (
+	// ForwardModeInline 重新组织正文并携带原附件转发
+	ForwardModeInline ForwardMode = "inline"
+	// ForwardModeAttachment 将原始邮件作为 message/rfc822 附件原样转发
+	ForwardModeAttachment ForwardMode = "attachment"
+)
+
+// NewSMTPClient 创建使用密码认证的 SMTP 客户端
+func NewSMTPClient(server ServerConfig, username, password string) *SMTPClient {
+	timeout := server.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSMTPTimeout
+	}
 	return &SMTPClient{
-		host:        "smtp.gmail.com",
-		port:        "587",
-		username:    username,
-		password:    password,
-		maxRetries:  3,
-		retryDelay:  2 * time.Second,
-		timeout:     30 * time.Second,
+		server:   server,
+		username: username,
+		password: password,
+		timeout:  timeout,
 	}
 }
 
-// ForwardEmail 转发邮件 - 使用改进的SMTP实现和重试机制
-func (sc *SMTPClient) ForwardEmail(email *Email, toEmail string) error {
+// NewOAuth2SMTPClient 创建使用 XOAUTH2 认证的 SMTP 客户端
+func NewOAuth2SMTPClient(server ServerConfig, username string, tokens TokenSource) *SMTPClient {
+	sc := NewSMTPClient(server, username, "")
+	sc.tokens = tokens
+	return sc
+}
+
+// SendMessage 发送已渲染的转发邮件（见 RenderForwardMessage），只尝试一次，失败重试由发送队列负责；
+// 失败时返回 *SendError，可以据此判断是否值得重试。
+// 发送（包括建立会话）不超过客户端的超时时间，ctx 取消时立即中断。
+func (sc *SMTPClient) SendMessage(ctx context.Context, toEmail, message string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
 	log.Printf("开始发送邮件到: %s", toEmail)
-	
-	// 构建邮件内容
-	message := sc.buildForwardMessage(email, toEmail)
-	
-	// 使用重试机制发送邮件
-	var lastErr error
-	for attempt := 1; attempt <= sc.maxRetries; attempt++ {
-		log.Printf("尝试发送邮件 - 第 %d/%d 次", attempt, sc.maxRetries)
-		
-		err := sc.sendEmailWithManualSMTP(toEmail, message)
-		if err == nil {
-			log.Printf("邮件成功发送到: %s (第%d次尝试)", toEmail, attempt)
-			return nil
-		}
-		
-		lastErr = err
-		log.Printf("第%d次尝试失败: %v", attempt, err)
-		
-		// 如果不是最后一次尝试，等待一段时间再重试
-		if attempt < sc.maxRetries {
-			log.Printf("等待 %v 后重试...", sc.retryDelay)
-			time.Sleep(sc.retryDelay)
+	return sc.sendEmailWithManualSMTP(ctx, toEmail, message)
+}
+
+// sendEmailWithManualSMTP 在复用的 SMTP 会话上发送邮件，失败时返回分类后的 *SendError
+//
+// 服务器拒绝邮件（SMTP 回复错误）时会话仍然可用，下一封邮件前的 RSET 会清除事务状态；
+// 网络错误说明会话已不可用，关闭后下一封邮件重新连接。
+func (sc *SMTPClient) sendEmailWithManualSMTP(ctx context.Context, toEmail, message string) error {
+	c, err := sc.acquireSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	// ctx 取消时将截止时间设为过去，使正在进行的读写立即返回；
+	// 回调在其他协程中执行，使用当前连接的副本，会话关闭后 sc.conn 为空
+	conn := sc.conn
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+	conn.SetDeadline(time.Now().Add(sc.timeout))
+
+	if err := sc.transaction(c, toEmail, message); err != nil {
+		if err.Code == 0 {
+			sc.closeSession(false)
 		}
+		return err
 	}
-	
-	return fmt.Errorf("发送邮件失败，已经进行%d次尝试: %w", sc.maxRetries, lastErr)
+
+	sc.sent++
+	sc.lastUsed = time.Now()
+	log.Printf("邮件成功发送")
+	return nil
 }
 
-// sendEmailWithManualSMTP 直接使用smtp.SendMail，简化实现
-func (sc *SMTPClient) sendEmailWithManualSMTP(toEmail, message string) error {
-	addr := fmt.Sprintf("%s:%s", sc.host, sc.port)
-	log.Printf("使用smtp.SendMail发送到: %s", addr)
-	
-	// 使用简化的认证和发送
-	auth := smtp.PlainAuth("", sc.username, sc.password, sc.host)
-	
-	err := smtp.SendMail(addr, auth, sc.username, []string{toEmail}, []byte(message))
+// transaction 在会话上执行一次 MAIL FROM/RCPT TO/DATA 事务
+func (sc *SMTPClient) transaction(c *smtp.Client, toEmail, message string) *SendError {
+	if err := c.Mail(sc.username); err != nil {
+		return classifySendError(smtpStageMail, err)
+	}
+	if err := c.Rcpt(toEmail); err != nil {
+		return classifySendError(smtpStageRcpt, err)
+	}
+
+	w, err := c.Data()
 	if err != nil {
-		log.Printf("smtp.SendMail失败: %v", err)
-		return fmt.Errorf("smtp.SendMail失败: %w", err)
+		return classifySendError(smtpStageData, err)
+	}
+	if _, err := w.Write([]byte(message)); err != nil {
+		return classifySendError(smtpStageData, fmt.Errorf("写入邮件内容失败: %w", err))
+	}
+	if err := w.Close(); err != nil {
+		return classifySendError(smtpStageData, err)
 	}
-	
-	log.Printf("邮件成功发送")
 	return nil
 }
 
+// acquireSession 返回可用的 SMTP 会话：复用现有会话前发送 RSET 确认连接仍然有效，
+// 会话空闲过久、发送邮件数达到上限或 RSET 失败时重新连接
+func (sc *SMTPClient) acquireSession(ctx context.Context) (*smtp.Client, error) {
+	if sc.session != nil {
+		sc.conn.SetDeadline(time.Now().Add(sc.timeout))
+		if time.Since(sc.lastUsed) > SMTPSessionIdleTimeout || sc.sent >= smtpSessionMaxMessages {
+			sc.closeSession(true)
+		} else if err := sc.session.Reset(); err != nil {
+			log.Printf("SMTP会话已断开，重新连接: %v", err)
+			sc.closeSession(false)
+		} else {
+			return sc.session, nil
+		}
+	}
+
+	c, conn, err := sc.openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sc.session = c
+	sc.conn = conn
+	sc.sent = 0
+	sc.lastUsed = time.Now()
+	return c, nil
+}
+
+// openSession 按配置的加密方式连接 SMTP 服务器并认证，整个过程不超过超时时间，ctx 取消时中断
+func (sc *SMTPClient) openSession(ctx context.Context) (*smtp.Client, net.Conn, error) {
+	log.Printf("连接SMTP服务器: %s (%s)", sc.server.Addr(), sc.server.TLSMode)
+
+	c, conn, err := sc.dial(ctx)
+	if err != nil {
+		return nil, nil, classifySendError(smtpStageConnect, err)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	// 服务器未声明 AUTH 扩展时（如本地测试服务器）跳过认证
+	if ok, _ := c.Extension("AUTH"); ok {
+		auth, err := sc.auth()
+		if err != nil {
+			c.Close()
+			return nil, nil, classifySendError(smtpStageAuth, fmt.Errorf("获取认证信息失败: %w", err))
+		}
+		if err := c.Auth(auth); err != nil {
+			c.Close()
+			return nil, nil, classifySendError(smtpStageAuth, err)
+		}
+	}
+	return c, conn, nil
+}
+
+// closeSession 关闭当前会话，quit 为 true 时先发送 QUIT 正常结束会话
+func (sc *SMTPClient) closeSession(quit bool) {
+	if sc.session == nil {
+		return
+	}
+	if quit {
+		sc.conn.SetDeadline(time.Now().Add(sc.timeout))
+		if err := sc.session.Quit(); err != nil {
+			log.Printf("关闭SMTP会话失败: %v", err)
+		}
+	}
+	sc.session.Close()
+	sc.session = nil
+	sc.conn = nil
+}
+
+// CloseIdle 关闭空闲超过 maxIdle 的会话
+func (sc *SMTPClient) CloseIdle(maxIdle time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.session != nil && time.Since(sc.lastUsed) > maxIdle {
+		sc.closeSession(true)
+	}
+}
+
+// Close 结束 SMTP 会话
+func (sc *SMTPClient) Close() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.closeSession(true)
+}
+
+// auth 返回密码认证或 XOAUTH2 认证
+func (sc *SMTPClient) auth() (smtp.Auth, error) {
+	if sc.tokens == nil {
+		return smtp.PlainAuth("", sc.username, sc.password, sc.server.Host), nil
+	}
+
+	token, err := sc.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	return &xoauth2Auth{username: sc.username, accessToken: token.AccessToken}, nil
+}
+
+// dial 按配置的加密方式建立 SMTP 会话，返回会话和底层连接；
+// 连接、TLS 握手、问候语和 STARTTLS 都不超过超时时间
+func (sc *SMTPClient) dial(ctx context.Context) (*smtp.Client, net.Conn, error) {
+	addr := sc.server.Addr()
+	dialer := &net.Dialer{Timeout: sc.timeout}
+
+	var tlsConfig *tls.Config
+	if sc.server.TLSMode != TLSModePlain {
+		var err error
+		if tlsConfig, err = sc.server.tlsConfig(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(sc.timeout))
+	// 下面会将 conn 替换为 TLS 连接，回调使用原始连接，避免与赋值并发
+	rawConn := conn
+	stop := context.AfterFunc(ctx, func() {
+		rawConn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if sc.server.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, sc.server.Host)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if sc.server.TLSMode == TLSModeStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, nil, &SendError{
+				Kind:      SendErrorTLS,
+				Permanent: true,
+				Stage:     smtpStageConnect,
+				Err:       fmt.Errorf("服务器 %s 不支持 STARTTLS", addr),
+			}
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("STARTTLS失败: %w", err)
+		}
+	}
+	return c, conn, nil
+}
+
 // buildForwardMessage 构建转发邮件内容
-func (sc *SMTPClient) buildForwardMessage(email *Email, toEmail string) string {
+//
+// 正文方式（ForwardModeInline）的邮件结构：
+//
+//	multipart/mixed（有附件时）
+//	├── multipart/related（HTML 引用了内嵌图片时）
+//	│   ├── multipart/alternative
+//	│   │   ├── text/plain
+//	│   │   └── text/html
+//	│   └── 内嵌图片（Content-ID）
+//	└── 附件
+//
+// 附件方式（ForwardModeAttachment）的邮件结构：
+//
+//	multipart/mixed
+//	├── text/plain（转发说明）
+//	└── message/rfc822（原始邮件）
+func (sc *SMTPClient) buildForwardMessage(email *Email, toEmail string, mode ForwardMode) string {
 	var message strings.Builder
 
 	// 邮件头
@@ -90,44 +320,106 @@ func (sc *SMTPClient) buildForwardMessage(email *Email, toEmail string) string {
 	message.WriteString(fmt.Sprintf("From: %s\r\n", sc.username))
 	message.WriteString(fmt.Sprintf("Subject: [转发] %s\r\n", email.Subject))
 	message.WriteString("MIME-Version: 1.0\r\n")
-	message.WriteString("Content-Type: multipart/alternative; boundary=\"boundary123\"\r\n")
+
+	var body mimePart
+	if mode == ForwardModeAttachment && len(email.Raw) > 0 {
+		body = sc.buildAttachedForwardBody(email)
+	} else {
+		if mode == ForwardModeAttachment {
+			log.Printf("原始邮件内容为空，改为正文方式转发: %s", email.Subject)
+		}
+		body = sc.buildForwardBody(email)
+	}
+	body.writeHeader(&message)
 	message.WriteString("\r\n")
+	message.Write(body.body)
+
+	return message.String()
+}
+
+// RenderForwardMessage 返回将要发送的转发邮件内容，不连接 SMTP 服务器，用于写入发送队列和规则试运行
+func (sc *SMTPClient) RenderForwardMessage(email *Email, toEmail string, mode ForwardMode) string {
+	return sc.buildForwardMessage(email, toEmail, mode)
+}
+
+// buildAttachedForwardBody 构建附件方式的转发正文：转发说明 + 原始邮件
+func (sc *SMTPClient) buildAttachedForwardBody(email *Email) mimePart {
+	var note strings.Builder
+	note.WriteString("---------- 转发邮件 ----------\r\n")
+	note.WriteString(fmt.Sprintf("发件人: %s\r\n", email.From))
+	note.WriteString(fmt.Sprintf("主题: %s\r\n", email.Subject))
+	note.WriteString(fmt.Sprintf("收件人: %s\r\n", email.To))
+	note.WriteString("------------------------------\r\n\r\n")
+	note.WriteString("原始邮件已作为附件原样转发，请查看附件。\r\n")
+
+	return multipartPart("mixed", []mimePart{
+		textPart("text/plain", note.String()),
+		rfc822Part(email.Raw),
+	})
+}
+
+// buildForwardBody 构建转发邮件正文及附件
+func (sc *SMTPClient) buildForwardBody(email *Email) mimePart {
+	alternatives := []mimePart{textPart("text/plain", forwardText(email))}
+	if email.HTML != "" {
+		alternatives = append(alternatives, textPart("text/html", forwardHTML(email)))
+	}
+	body := multipartPart("alternative", alternatives)
+
+	// 被 HTML 通过 cid: 引用的部分放入 multipart/related，其余作为普通附件；
+	// Outlook 等客户端的内嵌图片常常只有 Content-ID 而没有 Content-Disposition，因此不依赖 Inline 判断
+	var related, attachments []mimePart
+	for _, att := range email.Attachments {
+		if referencedByHTML(email.HTML, att) {
+			related = append(related, attachmentPart(att, true))
+		} else {
+			attachments = append(attachments, attachmentPart(att, false))
+		}
+	}
+
+	if len(related) > 0 {
+		body = multipartPart("related", append([]mimePart{body}, related...))
+	}
+	if len(attachments) > 0 {
+		body = multipartPart("mixed", append([]mimePart{body}, attachments...))
+	}
+	return body
+}
+
+// forwardText 构建纯文本转发正文
+func forwardText(email *Email) string {
+	var text strings.Builder
 
 	// 添加转发说明
-	message.WriteString("--boundary123\r\n")
-	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	message.WriteString("\r\n")
-	message.WriteString("---------- 转发邮件 ----------\r\n")
-	message.WriteString(fmt.Sprintf("发件人: %s\r\n", email.From))
-	message.WriteString(fmt.Sprintf("主题: %s\r\n", email.Subject))
-	message.WriteString(fmt.Sprintf("收件人: %s\r\n", email.To))
-	message.WriteString("---------- 邮件内容 ----------\r\n\r\n")
+	text.WriteString("---------- 转发邮件 ----------\r\n")
+	text.WriteString(fmt.Sprintf("发件人: %s\r\n", email.From))
+	text.WriteString(fmt.Sprintf("主题: %s\r\n", email.Subject))
+	text.WriteString(fmt.Sprintf("收件人: %s\r\n", email.To))
+	text.WriteString("---------- 邮件内容 ----------\r\n\r\n")
 
 	// 添加原邮件正文（纯文本）
 	if email.Body != "" {
-		message.WriteString(email.Body)
+		text.WriteString(email.Body)
 	} else {
-		message.WriteString("（此邮件无纯文本内容）")
+		text.WriteString("（此邮件无纯文本内容）")
 	}
-	message.WriteString("\r\n")
+	text.WriteString("\r\n")
 
-	// 如果有 HTML 内容，也添加进去
-	if email.HTML != "" {
-		message.WriteString("\r\n--boundary123\r\n")
-		message.WriteString("Content-Type: text/html; charset=utf-8\r\n")
-		message.WriteString("\r\n")
-		message.WriteString("<div style=\"border-left: 3px solid #ccc; padding-left: 10px; margin: 10px 0;\">")
-		message.WriteString("<h4>---------- 转发邮件 ----------</h4>")
-		message.WriteString(fmt.Sprintf("<p><strong>发件人:</strong> %s</p>", email.From))
-		message.WriteString(fmt.Sprintf("<p><strong>主题:</strong> %s</p>", email.Subject))
-		message.WriteString(fmt.Sprintf("<p><strong>收件人:</strong> %s</p>", email.To))
-		message.WriteString("<h4>---------- 邮件内容 ----------</h4>")
-		message.WriteString(email.HTML)
-		message.WriteString("</div>")
-	}
+	return text.String()
+}
 
-	message.WriteString("\r\n--boundary123--\r\n")
+// forwardHTML 构建 HTML 转发正文
+func forwardHTML(email *Email) string {
+	var html strings.Builder
 
-	return message.String()
-}
+	html.WriteString("<div style=\"border-left: 3px solid #ccc; padding-left: 10px; margin: 10px 0;\">")
+	html.WriteString("<h4>---------- 转发邮件 ----------</h4>")
+	html.WriteString(fmt.Sprintf("<p><strong>发件人:</strong> %s</p>", email.From))
+	html.WriteString(fmt.Sprintf("<p><strong>主题:</strong> %s</p>", email.Subject))
+	html.WriteString(fmt.Sprintf("<p><strong>收件人:</strong> %s</p>", email.To))
+	html.WriteString("<h4>---------- 邮件内容 ----------</h4>")
+	html.WriteString(email.HTML)
+	html.WriteString("</div>")
 
+	return html.String()
+}
@@ -0,0 +1,133 @@
+package gmail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// SMTP 发送失败原因分类
+const (
+	SendErrorTimeout           = "timeout"            // 连接或读写超时
+	SendErrorNetwork           = "network"            // 连接失败、连接被断开等网络错误
+	SendErrorTLS               = "tls"                // 证书校验失败或服务器不支持 STARTTLS
+	SendErrorAuthFailed        = "auth_failed"        // 认证失败或无法获取访问令牌
+	SendErrorSenderRejected    = "sender_rejected"    // MAIL FROM 被拒绝
+	SendErrorRecipientRejected = "recipient_rejected" // RCPT TO 被拒绝（收件人不存在、邮箱已满等）
+	SendErrorMessageRejected   = "message_rejected"   // 邮件内容被拒绝（大小、策略、垃圾邮件等）
+	SendErrorServerUnavailable = "server_unavailable" // 服务器暂不可用（421 等）
+	SendErrorUnknown           = "unknown"
+)
+
+// SMTP 会话阶段
+const (
+	smtpStageConnect = "连接SMTP服务器"
+	smtpStageAuth    = "SMTP认证"
+	smtpStageMail    = "MAIL FROM"
+	smtpStageRcpt    = "RCPT TO"
+	smtpStageData    = "DATA"
+)
+
+// enhancedCodePattern 匹配回复文本开头的增强状态码（RFC 3463），如 5.1.1
+var enhancedCodePattern = regexp.MustCompile(`^([245]\.\d{1,3}\.\d{1,3})\b`)
+
+// SendError 分类后的 SMTP 发送错误
+//
+// 4xx 回复和网络错误为临时错误，可以重试；5xx 回复和证书错误为永久错误，重试也不会成功。
+type SendError struct {
+	Kind      string // 失败原因分类，见 SendError* 常量
+	Permanent bool   // 是否为永久错误
+	Code      int    // SMTP 回复码，非 SMTP 回复的错误为 0
+	Enhanced  string // 增强状态码，服务器未返回时为空
+	Stage     string // 出错的会话阶段
+	Err       error
+}
+
+// Error 实现 error 接口
+func (e *SendError) Error() string {
+	return fmt.Sprintf("%s失败 [%s]: %v", e.Stage, e.Kind, e.Err)
+}
+
+// Unwrap 返回原始错误
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// classifySendError 根据 SMTP 回复码、增强状态码和出错阶段对发送错误分类
+func classifySendError(stage string, err error) *SendError {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr
+	}
+
+	se := &SendError{Kind: SendErrorUnknown, Stage: stage, Err: err}
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		se.Code = reply.Code
+		se.Permanent = reply.Code >= 500
+		if m := enhancedCodePattern.FindStringSubmatch(reply.Msg); m != nil {
+			se.Enhanced = m[1]
+		}
+		se.Kind = replyKind(stage, reply.Code, se.Enhanced)
+		return se
+	}
+
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &certErr):
+		se.Kind = SendErrorTLS
+		se.Permanent = true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		se.Kind = SendErrorTimeout
+	case stage == smtpStageAuth:
+		// 获取访问令牌失败等，可能是网络问题，按临时错误重试
+		se.Kind = SendErrorAuthFailed
+	default:
+		// 连接失败、连接被断开（EOF）等按网络错误重试
+		se.Kind = SendErrorNetwork
+	}
+	return se
+}
+
+// replyKind 根据 SMTP 回复判断失败原因
+func replyKind(stage string, code int, enhanced string) string {
+	switch {
+	case code == 421:
+		return SendErrorServerUnavailable
+	case code == 530 || code == 534 || code == 535 || (code == 454 && stage == smtpStageAuth):
+		return SendErrorAuthFailed
+	}
+
+	// 增强状态码的类别：x.1.x 地址状态，x.2.x 邮箱状态，x.3.x/x.6.x 邮件内容
+	if parts := strings.Split(enhanced, "."); len(parts) == 3 {
+		switch parts[1] {
+		case "1", "2":
+			if stage == smtpStageMail {
+				return SendErrorSenderRejected
+			}
+			return SendErrorRecipientRejected
+		case "3", "6":
+			return SendErrorMessageRejected
+		}
+	}
+
+	switch stage {
+	case smtpStageAuth:
+		return SendErrorAuthFailed
+	case smtpStageMail:
+		return SendErrorSenderRejected
+	case smtpStageRcpt:
+		return SendErrorRecipientRejected
+	case smtpStageData:
+		return SendErrorMessageRejected
+	case smtpStageConnect:
+		return SendErrorServerUnavailable
+	}
+	return SendErrorUnknown
+}
@@ -0,0 +1,124 @@
+package gmail
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"testing"
+)
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		name      string
+		stage     string
+		err       error
+		kind      string
+		permanent bool
+		code      int
+		enhanced  string
+	}{
+		{
+			name:  "421 service unavailable",
+			stage: smtpStageMail,
+			err:   &textproto.Error{Code: 421, Msg: "4.7.0 Try again later, closing connection."},
+			kind:  SendErrorServerUnavailable, code: 421, enhanced: "4.7.0",
+		},
+		{
+			name:  "535 bad credentials",
+			stage: smtpStageAuth,
+			err:   &textproto.Error{Code: 535, Msg: "5.7.8 Username and Password not accepted."},
+			kind:  SendErrorAuthFailed, permanent: true, code: 535, enhanced: "5.7.8",
+		},
+		{
+			name:  "454 auth temporarily unavailable",
+			stage: smtpStageAuth,
+			err:   &textproto.Error{Code: 454, Msg: "4.7.0 Too many login attempts"},
+			kind:  SendErrorAuthFailed, code: 454, enhanced: "4.7.0",
+		},
+		{
+			name:  "550 recipient does not exist",
+			stage: smtpStageRcpt,
+			err:   &textproto.Error{Code: 550, Msg: "5.1.1 The email account that you tried to reach does not exist."},
+			kind:  SendErrorRecipientRejected, permanent: true, code: 550, enhanced: "5.1.1",
+		},
+		{
+			name:  "452 mailbox full",
+			stage: smtpStageRcpt,
+			err:   &textproto.Error{Code: 452, Msg: "4.2.2 The email account that you tried to reach is over quota."},
+			kind:  SendErrorRecipientRejected, code: 452, enhanced: "4.2.2",
+		},
+		{
+			name:  "552 message too large",
+			stage: smtpStageData,
+			err:   &textproto.Error{Code: 552, Msg: "5.3.4 Your message exceeded Google's message size limits."},
+			kind:  SendErrorMessageRejected, permanent: true, code: 552, enhanced: "5.3.4",
+		},
+		{
+			name:  "550 sender address rejected",
+			stage: smtpStageMail,
+			err:   &textproto.Error{Code: 550, Msg: "5.1.0 Sender address rejected"},
+			kind:  SendErrorSenderRejected, permanent: true, code: 550, enhanced: "5.1.0",
+		},
+		{
+			name:  "553 sender without enhanced code",
+			stage: smtpStageMail,
+			err:   &textproto.Error{Code: 553, Msg: "Sender not allowed"},
+			kind:  SendErrorSenderRejected, permanent: true, code: 553,
+		},
+		{
+			name:  "certificate verification failed",
+			stage: smtpStageConnect,
+			err:   &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}},
+			kind:  SendErrorTLS, permanent: true,
+		},
+		{
+			name:  "read timeout",
+			stage: smtpStageData,
+			err:   &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			kind:  SendErrorTimeout,
+		},
+		{
+			name:  "connection refused",
+			stage: smtpStageConnect,
+			err:   &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")},
+			kind:  SendErrorNetwork,
+		},
+		{
+			name:  "connection closed",
+			stage: smtpStageRcpt,
+			err:   io.EOF,
+			kind:  SendErrorNetwork,
+		},
+		{
+			name:  "token refresh failed",
+			stage: smtpStageAuth,
+			err:   errors.New("获取认证信息失败: invalid_grant"),
+			kind:  SendErrorAuthFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifySendError(tt.stage, tt.err)
+			if got.Kind != tt.kind || got.Permanent != tt.permanent || got.Code != tt.code || got.Enhanced != tt.enhanced {
+				t.Errorf("classifySendError = {Kind: %q, Permanent: %v, Code: %d, Enhanced: %q}, want {Kind: %q, Permanent: %v, Code: %d, Enhanced: %q}",
+					got.Kind, got.Permanent, got.Code, got.Enhanced, tt.kind, tt.permanent, tt.code, tt.enhanced)
+			}
+			if got.Stage != tt.stage || !errors.Is(got, tt.err) {
+				t.Errorf("classifySendError lost stage or original error: %v", got)
+			}
+		})
+	}
+}
+
+func TestClassifySendErrorKeepsClassified(t *testing.T) {
+	inner := classifySendError(smtpStageAuth, &textproto.Error{Code: 535, Msg: "5.7.8 Bad credentials"})
+	wrapped := classifySendError(smtpStageConnect, inner)
+	if wrapped != inner {
+		t.Errorf("classifySendError reclassified an existing SendError: %v", wrapped)
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// 账户认证方式
+const (
+	AuthModePassword = "password"
+	AuthModeOAuth2   = "oauth2"
+)
+
+// Account 邮箱账户表，每个启用的账户由调度器使用独立的处理器按自己的计划收取和转发邮件
+//
+// 服务器、检查间隔、同步模式等字段为空时使用全局配置；OAuth2 刷新令牌保存在 oauth_tokens 表中。
+type Account struct {
+	ID       uint   `gorm:"primarykey" json:"id"`
+	Name     string `gorm:"not null;size:100;comment:账户名称" json:"name"`
+	Email    string `gorm:"uniqueIndex;not null;size:255;comment:登录账户及转发发件人地址" json:"email"`
+	AuthMode string `gorm:"size:20;default:password;comment:认证方式(password/oauth2)" json:"auth_mode"`
+	Password string `gorm:"size:255;comment:应用专用密码" json:"-"`
+
+	OAuth2ClientID     string `gorm:"size:255;comment:OAuth2客户端ID" json:"oauth2_client_id"`
+	OAuth2ClientSecret string `gorm:"size:255;comment:OAuth2客户端密钥" json:"-"`
+
+	IMAPHost    string `gorm:"size:255;comment:IMAP服务器" json:"imap_host"`
+	IMAPPort    string `gorm:"size:10;comment:IMAP端口" json:"imap_port"`
+	IMAPTLSMode string `gorm:"size:20;comment:IMAP加密方式" json:"imap_tls_mode"`
+	SMTPHost    string `gorm:"size:255;comment:SMTP服务器" json:"smtp_host"`
+	SMTPPort    string `gorm:"size:10;comment:SMTP端口" json:"smtp_port"`
+	SMTPTLSMode string `gorm:"size:20;comment:SMTP加密方式" json:"smtp_tls_mode"`
+
+	// Folders 要处理的邮箱文件夹（Gmail 标签也以文件夹形式出现，如 "Alerts" 或 "[Gmail]/All Mail"），为空时只处理 INBOX
+	Folders []string `gorm:"serializer:json;type:text;comment:邮箱文件夹(JSON)" json:"folders"`
+
+	// GmailLabels/GmailSearch 在每个文件夹中附加的 Gmail 搜索条件（X-GM-LABELS/X-GM-RAW），需要服务器支持 X-GM-EXT-1
+	GmailLabels []string `gorm:"serializer:json;type:text;comment:Gmail标签过滤(JSON)" json:"gmail_labels"`
+	GmailSearch string   `gorm:"size:500;comment:Gmail搜索语法过滤" json:"gmail_search"`
+
+	CheckInterval string `gorm:"size:20;comment:检查间隔" json:"check_interval"`
+	SyncMode      string `gorm:"size:20;comment:同步模式(cron/idle)" json:"sync_mode"`
+	Enabled       bool   `gorm:"comment:是否启用" json:"enabled"`
+
+	// SendLimitPerMinute/SendLimitPerDay 账户的发送限额，为 0 时使用全局配置，为 -1 时不限制
+	SendLimitPerMinute int `gorm:"default:0;comment:每分钟发送限额" json:"send_limit_per_minute"`
+	SendLimitPerDay    int `gorm:"default:0;comment:每天发送限额" json:"send_limit_per_day"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeAddressPattern 规范化并校验地址匹配模式
+//
+// 支持三种格式：
+//   - 精确地址：user@example.com
+//   - 域名：example.com（也可写作 @example.com）
+//   - 通配子域名：*.example.com（匹配 a.example.com、b.a.example.com，不匹配 example.com 本身）
+func NormalizeAddressPattern(pattern string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(pattern))
+	normalized = strings.TrimPrefix(normalized, "@")
+	if normalized == "" {
+		return "", fmt.Errorf("匹配模式不能为空")
+	}
+
+	if at := strings.LastIndex(normalized, "@"); at >= 0 {
+		if at == 0 || at == len(normalized)-1 || strings.Contains(normalized, "*") {
+			return "", fmt.Errorf("邮箱地址格式不正确: %s", pattern)
+		}
+	} else {
+		domain := strings.TrimPrefix(normalized, "*.")
+		if strings.Contains(domain, "*") || !strings.Contains(domain, ".") {
+			return "", fmt.Errorf("域名格式不正确: %s", pattern)
+		}
+	}
+
+	return normalized, nil
+}
+
+// MatchAddressPattern 检查邮箱地址是否匹配已规范化的地址匹配模式
+func MatchAddressPattern(pattern, address string) bool {
+	address = strings.ToLower(strings.TrimSpace(address))
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := address[at+1:]
+
+	switch {
+	case strings.Contains(pattern, "@"):
+		return address == pattern
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(domain, pattern[1:])
+	default:
+		return domain == pattern
+	}
+}
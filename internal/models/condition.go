@@ -0,0 +1,48 @@
+package models
+
+// 条件组合方式
+const (
+	ConditionOpAnd = "and"
+	ConditionOpOr  = "or"
+	ConditionOpNot = "not"
+)
+
+// 条件字段
+const (
+	ConditionFieldSubject    = "subject"     // 邮件主题
+	ConditionFieldFrom       = "from"        // 发件人地址
+	ConditionFieldFromDomain = "from_domain" // 发件人域名
+	ConditionFieldTo         = "to"          // 任一收件人地址
+	ConditionFieldCc         = "cc"          // 任一抄送地址
+	ConditionFieldHeader     = "header"      // 任意邮件头，需指定 Header
+	ConditionFieldBody       = "body"        // 纯文本正文
+	ConditionFieldHTML       = "html"        // HTML 正文
+)
+
+// MatchTypeExists 条件匹配方式：字段存在且非空（常用于邮件头）
+const MatchTypeExists = "exists"
+
+// Condition 规则条件树节点，以 JSON 形式保存在转发规则中
+//
+// Op 为 and/or/not 时是组合节点，子条件保存在 Conditions 中（not 只能有一个子条件）；
+// Op 为空时是叶子节点，使用 MatchType 将 Field（及 Header）的值与 Value 比较。
+//
+// 示例：发件人域名为 example.com 且（X-Priority 为 1 或主题包含"加急"）
+//
+//	{"op": "and", "conditions": [
+//	  {"field": "from_domain", "value": "example.com"},
+//	  {"op": "or", "conditions": [
+//	    {"field": "header", "header": "X-Priority", "match_type": "prefix", "value": "1"},
+//	    {"field": "subject", "match_type": "contains", "value": "加急"}
+//	  ]}
+//	]}
+type Condition struct {
+	Op         string      `json:"op,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	Field      string `json:"field,omitempty"`
+	Header     string `json:"header,omitempty"`
+	MatchType  string `json:"match_type,omitempty"`
+	Value      string `json:"value,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+}
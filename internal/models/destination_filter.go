@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 转发目标过滤类型
+const (
+	FilterTypeAllow = "allow"
+	FilterTypeDeny  = "deny"
+)
+
+// DestinationFilter 转发目标白名单/黑名单表，Pattern 格式见 NormalizeAddressPattern
+type DestinationFilter struct {
+	gorm.Model
+	Pattern string `gorm:"uniqueIndex:idx_pattern_type;not null;size:255;comment:地址/域名/通配子域名" json:"pattern"`
+	Type    string `gorm:"uniqueIndex:idx_pattern_type;not null;size:10;comment:类型 allow/deny" json:"type"`
+	Active  bool   `gorm:"comment:是否启用" json:"active"`
+	Note    string `gorm:"size:255;comment:备注" json:"note"`
+}
+
+// Normalize 规范化并校验过滤规则
+func (f *DestinationFilter) Normalize() error {
+	f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+	if f.Type != FilterTypeAllow && f.Type != FilterTypeDeny {
+		return fmt.Errorf("类型只能为 allow 或 deny")
+	}
+
+	pattern, err := NormalizeAddressPattern(f.Pattern)
+	if err != nil {
+		return err
+	}
+	f.Pattern = pattern
+	return nil
+}
+
+// Matches 检查邮箱地址是否匹配该过滤规则
+func (f *DestinationFilter) Matches(address string) bool {
+	return MatchAddressPattern(f.Pattern, address)
+}
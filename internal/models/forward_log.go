@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 转发结果状态
+const (
+	ForwardStatusQueued       = "queued" // 已写入发送队列，等待发送或重试
+	ForwardStatusForwarded    = "forwarded"
+	ForwardStatusSkipped      = "skipped"
+	ForwardStatusFailed       = "failed"
+	ForwardStatusDuplicate    = "duplicate"
+	ForwardStatusRejected     = "rejected"
+	ForwardStatusUnauthorized = "unauthorized"
+)
+
+// ForwardLog 邮件转发日志表，记录每封处理过的邮件的结果
+type ForwardLog struct {
+	gorm.Model
+	MessageID   string    `gorm:"index;size:255;comment:原邮件Message-ID" json:"message_id"`
+	Subject     string    `gorm:"size:500;comment:原邮件主题" json:"subject"`
+	From        string    `gorm:"column:from_address;size:255;comment:原邮件发件人" json:"from"`
+	Keyword     string    `gorm:"index;size:100;comment:解析出的关键字" json:"keyword"`
+	TargetEmail string    `gorm:"index;size:255;comment:转发目标邮箱" json:"target_email"`
+	AccountID   *uint     `gorm:"index;comment:来源账户ID" json:"account_id"`
+	Mailbox     string    `gorm:"index;size:255;comment:来源邮箱文件夹" json:"mailbox"`
+	RuleID      *uint     `gorm:"index;comment:匹配的转发规则ID" json:"rule_id"`
+	Status      string    `gorm:"index;not null;size:20;comment:处理结果 queued/forwarded/skipped/failed/duplicate/rejected/unauthorized" json:"status"`
+	Error       string    `gorm:"type:text;comment:失败或跳过原因" json:"error"`
+	ErrorKind   string    `gorm:"index;size:30;comment:发送失败原因分类" json:"error_kind,omitempty"`
+	Attempts    int       `gorm:"default:0;comment:发送尝试次数" json:"attempts"`
+	OutboxJobID *uint     `gorm:"index;comment:发送队列任务ID" json:"outbox_job_id"`
+	ProcessedAt time.Time `gorm:"index;comment:处理时间" json:"processed_at"`
+
+	// Labels 原邮件的 Gmail 标签
+	Labels []string `gorm:"serializer:json;type:text;comment:Gmail标签(JSON)" json:"labels,omitempty"`
+
+	// MatchTrace 该邮件按优先级评估过的每条规则及是否命中
+	MatchTrace []RuleTrace `gorm:"serializer:json;type:text;comment:规则匹配记录(JSON)" json:"match_trace"`
+}
+
+// RuleTrace 单条规则的匹配记录
+type RuleTrace struct {
+	RuleID   uint   `json:"rule_id"`
+	Keyword  string `json:"keyword"`
+	Priority int    `json:"priority"`
+	Matched  bool   `json:"matched"`
+	Stop     bool   `json:"stop,omitempty"` // 命中且停止匹配后续规则
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// ForwardedMessage 已转发邮件记录表，用于防止同一封邮件被重复转发
+type ForwardedMessage struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	MessageKey  string    `gorm:"uniqueIndex:idx_message_target;not null;size:255;comment:邮件唯一标识（Message-ID或邮件头哈希）" json:"message_key"`
+	TargetEmail string    `gorm:"uniqueIndex:idx_message_target;not null;size:255;comment:转发目标邮箱" json:"target_email"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -4,9 +4,53 @@ import (
 	"gorm.io/gorm"
 )
 
+// 转发方式
+const (
+	ForwardModeInline     = "inline"     // 正文方式：重新组织正文并携带原附件
+	ForwardModeAttachment = "attachment" // 附件方式：原始邮件作为 message/rfc822 附件
+)
+
+// 关键字匹配方式
+const (
+	MatchTypeExact    = "exact"    // 精确匹配
+	MatchTypePrefix   = "prefix"   // 前缀匹配
+	MatchTypeContains = "contains" // 包含匹配
+	MatchTypeRegex    = "regex"    // 正则表达式
+	MatchTypeGlob     = "glob"     // 通配符（* ? [...]）
+	MatchTypeAny      = "any"      // 不匹配关键字，仅使用条件树
+)
+
 // ForwardingRule 转发规则表
 type ForwardingRule struct {
 	gorm.Model
-	Keyword string `gorm:"uniqueIndex;not null;size:100;comment:匹配关键字" json:"keyword"`
-	Active  bool   `gorm:"default:true;comment:是否启用" json:"active"`
-}
\ No newline at end of file
+	Keyword     string `gorm:"uniqueIndex;not null;size:100;comment:匹配关键字或模式" json:"keyword"`
+	Active      bool   `gorm:"default:true;comment:是否启用" json:"active"`
+	MatchType   string `gorm:"not null;size:20;default:exact;comment:匹配方式 exact/prefix/contains/regex/glob/any" json:"match_type"`
+	IgnoreCase  bool   `gorm:"default:false;comment:是否忽略大小写" json:"ignore_case"`
+	ForwardMode string `gorm:"not null;size:20;default:inline;comment:转发方式 inline/attachment" json:"forward_mode"`
+
+	// RequireSenderAuth 是否要求发件人通过 SPF/DKIM/DMARC 认证
+	RequireSenderAuth bool `gorm:"default:false;comment:是否要求发件人认证" json:"require_sender_auth"`
+
+	// Priority 匹配优先级，数值越小越先匹配；StopProcessing 表示命中后不再匹配后续规则
+	Priority       int  `gorm:"default:0;index;comment:匹配优先级(越小越优先)" json:"priority"`
+	StopProcessing bool `gorm:"default:false;comment:命中后停止匹配后续规则" json:"stop_processing"`
+
+	// Conditions 附加条件树，关键字匹配后还需满足条件才会转发，为空表示无附加条件
+	Conditions *Condition `gorm:"serializer:json;type:text;comment:附加条件(JSON)" json:"conditions"`
+
+	// SuccessActions/FailureActions 转发成功或失败后对原邮件执行的动作（标签、移动、归档、星标、删除）
+	SuccessActions []MailAction `gorm:"serializer:json;type:text;comment:转发成功后动作(JSON)" json:"success_actions"`
+	FailureActions []MailAction `gorm:"serializer:json;type:text;comment:转发失败后动作(JSON)" json:"failure_actions"`
+
+	// Recipients 固定收件人，邮件主题中没有邮箱地址时转发给这些收件人
+	Recipients []Recipient `gorm:"many2many:rule_recipients;" json:"recipients,omitempty"`
+
+	// Accounts 规则适用的邮箱账户，为空表示适用于所有账户
+	Accounts []Account `gorm:"many2many:rule_accounts;" json:"accounts,omitempty"`
+}
+
+// ValidForwardMode 检查转发方式是否合法
+func ValidForwardMode(mode string) bool {
+	return mode == ForwardModeInline || mode == ForwardModeAttachment
+}
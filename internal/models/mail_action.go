@@ -0,0 +1,28 @@
+package models
+
+// 邮件处理后动作类型
+const (
+	MailActionAddLabels    = "add_labels"    // 添加 Gmail 标签，需指定 Labels
+	MailActionRemoveLabels = "remove_labels" // 移除 Gmail 标签，需指定 Labels
+	MailActionMove         = "move"          // 移动到文件夹，需指定 Folder
+	MailActionArchive      = "archive"       // 归档：Gmail 移除收件箱标签，其他服务器移动到归档文件夹
+	MailActionFlag         = "flag"          // 添加星标（\Flagged）
+	MailActionDelete       = "delete"        // 删除：移动到废纸篓
+)
+
+// MailAction 转发后对原邮件执行的动作，以 JSON 形式保存在转发规则中
+//
+// 示例：转发成功后添加"已转发"标签并归档，转发失败时移动到 Forward-Failed 文件夹
+//
+//	"success_actions": [{"type": "add_labels", "labels": ["已转发"]}, {"type": "archive"}]
+//	"failure_actions": [{"type": "move", "folder": "Forward-Failed"}]
+type MailAction struct {
+	Type   string   `json:"type"`
+	Labels []string `json:"labels,omitempty"`
+	Folder string   `json:"folder,omitempty"` // move 的目标文件夹；archive 在非 Gmail 服务器上的归档文件夹（可选）
+}
+
+// Terminal 检查动作执行后邮件是否会离开当前文件夹
+func (a MailAction) Terminal() bool {
+	return a.Type == MailActionMove || a.Type == MailActionArchive || a.Type == MailActionDelete
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// 转发后动作组状态
+const (
+	MailActionGroupCollecting = "collecting" // 邮件仍在处理中，还在写入发送任务
+	MailActionGroupPending    = "pending"    // 等待所有发送任务完成
+	MailActionGroupDone       = "done"       // 已执行转发后动作
+)
+
+// MailActionGroup 单封原邮件的转发后动作表
+//
+// 一封邮件可能转发给多个目标，每个目标是一个发送任务；转发后动作针对原邮件，必须在所有目标的发送任务
+// 都完成（发送成功、进入死信或被丢弃）后只执行一次，否则先完成的任务移动或删除邮件后，其他任务的动作会失效。
+type MailActionGroup struct {
+	ID         uint          `gorm:"primarykey" json:"id"`
+	AccountID  uint          `gorm:"index;comment:邮箱账户ID(0为环境变量配置的默认账户)" json:"account_id"`
+	MessageKey string        `gorm:"index;size:255;comment:邮件唯一标识" json:"message_key"`
+	Subject    string        `gorm:"size:500;comment:原邮件主题" json:"subject"`
+	Mailbox    string        `gorm:"size:255;comment:原邮件所在文件夹" json:"mailbox"`
+	UID        uint32        `gorm:"comment:原邮件UID" json:"uid"`
+	Rules      []RuleActions `gorm:"serializer:json;type:text;comment:各规则的转发后动作及处理时已确定的结果(JSON)" json:"rules"`
+	Status     string        `gorm:"index;not null;size:20;comment:状态 collecting/pending/done" json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// RuleActions 单条规则的转发后动作
+//
+// 规则有目标转发失败（处理时失败或发送任务进入死信）时执行失败动作，否则有目标转发成功时执行成功动作。
+type RuleActions struct {
+	RuleID         uint         `json:"rule_id"`
+	SuccessActions []MailAction `json:"success_actions,omitempty"`
+	FailureActions []MailAction `json:"failure_actions,omitempty"`
+	Succeeded      bool         `json:"succeeded"` // 处理时已有目标转发成功（如已转发过）
+	Failed         bool         `json:"failed"`    // 处理时已有目标转发失败
+}
+
+// Actions 根据规则的转发结果返回要执行的动作
+func (r RuleActions) Actions() []MailAction {
+	if r.Failed {
+		return r.FailureActions
+	}
+	if r.Succeeded {
+		return r.SuccessActions
+	}
+	return nil
+}
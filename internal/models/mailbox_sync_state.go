@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// MailboxSyncState 邮箱增量同步状态表，记录每个邮箱的 UIDVALIDITY 和已处理的最大 UID
+type MailboxSyncState struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Account      string     `gorm:"uniqueIndex:idx_account_mailbox;not null;size:255;comment:邮箱账户" json:"account"`
+	Mailbox      string     `gorm:"uniqueIndex:idx_account_mailbox;not null;size:255;comment:邮箱文件夹" json:"mailbox"`
+	UIDValidity  uint32     `gorm:"comment:IMAP UIDVALIDITY" json:"uid_validity"`
+	LastUID      uint32     `gorm:"comment:已处理的最大UID" json:"last_uid"`
+	LastSyncedAt *time.Time `gorm:"comment:上次同步时间" json:"last_synced_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
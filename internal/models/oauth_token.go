@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthToken OAuth2 令牌表，保存每个邮箱账户的刷新令牌和最近一次获取的访问令牌
+type OAuthToken struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Account      string     `gorm:"uniqueIndex;not null;size:255;comment:邮箱账户" json:"account"`
+	RefreshToken string     `gorm:"type:text;not null;comment:刷新令牌" json:"-"`
+	AccessToken  string     `gorm:"type:text;comment:访问令牌" json:"-"`
+	Expiry       *time.Time `gorm:"comment:访问令牌过期时间" json:"expiry"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
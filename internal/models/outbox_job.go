@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// 转发任务状态
+const (
+	OutboxStatusPending   = "pending"   // 等待发送（包括等待重试）
+	OutboxStatusSending   = "sending"   // 正在发送
+	OutboxStatusSent      = "sent"      // 发送成功
+	OutboxStatusDead      = "dead"      // 超过最大尝试次数，进入死信
+	OutboxStatusDiscarded = "discarded" // 已手动丢弃
+)
+
+// OutboxJob 待发送的转发任务表（发件箱）
+//
+// 邮件处理时只渲染转发邮件并写入发件箱，由后台发送队列按指数退避重试发送；
+// 发送完成后更新对应的转发日志；原邮件的所有发送任务都完成后，按动作组（MailActionGroup）执行一次转发后动作。
+// SMTP 永久错误（5xx）不再重试，直接进入死信；认证失败等账户级错误暂停账户的发送，不进入死信。
+type OutboxJob struct {
+	ID           uint   `gorm:"primarykey" json:"id"`
+	AccountID    uint   `gorm:"index;comment:发送账户ID(0为环境变量配置的默认账户)" json:"account_id"`
+	ForwardLogID uint   `gorm:"index;comment:对应的转发日志ID" json:"forward_log_id"`
+	RuleID       *uint  `gorm:"index;comment:匹配的转发规则ID" json:"rule_id"`
+	MessageKey   string `gorm:"index:idx_outbox_message_target;not null;size:255;comment:邮件唯一标识" json:"message_key"`
+	TargetEmail  string `gorm:"index:idx_outbox_message_target;not null;size:255;comment:转发目标邮箱" json:"target_email"`
+	Subject      string `gorm:"size:500;comment:原邮件主题" json:"subject"`
+	Message      string `gorm:"type:longtext;comment:渲染好的转发邮件" json:"-"`
+
+	// DedupKey 邮件标识和转发目标的哈希，未丢弃的任务唯一，防止并发处理同一封邮件时重复写入发送队列；丢弃后置空
+	DedupKey *string `gorm:"uniqueIndex:idx_outbox_dedup;size:64;comment:去重键(丢弃后为空)" json:"-"`
+
+	// Mailbox/UID 原邮件位置；ActionGroupID 原邮件的转发后动作组，回溯处理的任务不执行动作，为空
+	Mailbox       string `gorm:"size:255;comment:原邮件所在文件夹" json:"mailbox"`
+	UID           uint32 `gorm:"comment:原邮件UID" json:"uid"`
+	ActionGroupID *uint  `gorm:"index;comment:转发后动作组ID" json:"action_group_id"`
+
+	Status        string     `gorm:"index:idx_outbox_status_next;not null;size:20;comment:状态 pending/sending/sent/dead/discarded" json:"status"`
+	Attempts      int        `gorm:"default:0;comment:已尝试次数" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index:idx_outbox_status_next;comment:下次尝试时间" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text;comment:最近一次失败原因" json:"last_error"`
+	ErrorKind     string     `gorm:"index;size:30;comment:失败原因分类" json:"error_kind,omitempty"`
+	SentAt        *time.Time `gorm:"comment:发送成功时间" json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
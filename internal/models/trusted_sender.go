@@ -0,0 +1,31 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// TrustedSender 可信发件人表，只有可信发件人的邮件才会触发转发
+//
+// RuleID 为空时为全局规则，否则只作用于对应的转发规则。Pattern 格式见 NormalizeAddressPattern。
+type TrustedSender struct {
+	gorm.Model
+	Pattern string `gorm:"not null;size:255;comment:发件人地址/域名/通配子域名" json:"pattern"`
+	RuleID  *uint  `gorm:"index;comment:所属转发规则ID，为空表示全局" json:"rule_id"`
+	Active  bool   `gorm:"comment:是否启用" json:"active"`
+	Note    string `gorm:"size:255;comment:备注" json:"note"`
+}
+
+// Normalize 规范化并校验匹配模式
+func (s *TrustedSender) Normalize() error {
+	pattern, err := NormalizeAddressPattern(s.Pattern)
+	if err != nil {
+		return err
+	}
+	s.Pattern = pattern
+	return nil
+}
+
+// Matches 检查发件人地址是否匹配
+func (s *TrustedSender) Matches(address string) bool {
+	return MatchAddressPattern(s.Pattern, address)
+}
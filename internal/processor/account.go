@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gmail-forwarding/internal/config"
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultAccount 返回通过环境变量配置的默认账户（ID 为 0），未设置 GMAIL_USER 时返回 nil
+func DefaultAccount() *models.Account {
+	cfg := config.GlobalConfig
+	if cfg.GmailUser == "" {
+		return nil
+	}
+	return &models.Account{
+		Name:               cfg.GmailUser,
+		Email:              cfg.GmailUser,
+		AuthMode:           cfg.AuthMode,
+		Password:           cfg.GmailPassword,
+		OAuth2ClientID:     cfg.OAuth2ClientID,
+		OAuth2ClientSecret: cfg.OAuth2ClientSecret,
+		Folders:            cfg.Folders,
+		GmailLabels:        cfg.GmailLabels,
+		GmailSearch:        cfg.GmailSearch,
+		Enabled:            true,
+	}
+}
+
+// LoadAccounts 加载所有启用的邮箱账户，数据库中没有启用的账户时使用环境变量配置的默认账户
+func LoadAccounts() ([]models.Account, error) {
+	db := database.GetDB()
+	var accounts []models.Account
+
+	if err := db.Where("enabled = ?", true).Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("加载邮箱账户失败: %w", err)
+	}
+	if len(accounts) == 0 {
+		if account := DefaultAccount(); account != nil {
+			accounts = append(accounts, *account)
+		}
+	}
+	return accounts, nil
+}
+
+// ResolveAccount 根据 ID 查找账户；id 为 0 时要求只有一个可用账户并返回该账户
+func ResolveAccount(id uint) (*models.Account, error) {
+	if id != 0 {
+		db := database.GetDB()
+		var account models.Account
+		if err := db.First(&account, id).Error; err != nil {
+			return nil, fmt.Errorf("邮箱账户 %d 不存在: %w", id, err)
+		}
+		return &account, nil
+	}
+
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	switch len(accounts) {
+	case 0:
+		return nil, errors.New("没有可用的邮箱账户，请配置GMAIL_USER或通过API添加账户")
+	case 1:
+		return &accounts[0], nil
+	default:
+		return nil, errors.New("存在多个邮箱账户，请指定账户ID")
+	}
+}
+
+// ValidateAccount 校验账户配置
+func ValidateAccount(account *models.Account) error {
+	if strings.TrimSpace(account.Email) == "" {
+		return errors.New("邮箱账户不能为空")
+	}
+	if account.AuthMode != models.AuthModePassword && account.AuthMode != models.AuthModeOAuth2 {
+		return fmt.Errorf("认证方式只能为 %s 或 %s", models.AuthModePassword, models.AuthModeOAuth2)
+	}
+	for _, mode := range []string{account.IMAPTLSMode, account.SMTPTLSMode} {
+		if mode != "" && !gmail.ValidTLSMode(mode) {
+			return fmt.Errorf("加密方式 %s 无效，可选 tls、starttls、plain", mode)
+		}
+	}
+	if account.CheckInterval != "" {
+		if _, err := time.ParseDuration(account.CheckInterval); err != nil {
+			return fmt.Errorf("检查间隔 %s 无效: %w", account.CheckInterval, err)
+		}
+	}
+	for _, folder := range account.Folders {
+		if strings.TrimSpace(folder) == "" {
+			return errors.New("邮箱文件夹名称不能为空")
+		}
+	}
+	if account.SyncMode != "" && account.SyncMode != config.SyncModeCron && account.SyncMode != config.SyncModeIdle {
+		return fmt.Errorf("同步模式只能为 %s 或 %s", config.SyncModeCron, config.SyncModeIdle)
+	}
+	if account.SendLimitPerMinute < -1 || account.SendLimitPerDay < -1 {
+		return errors.New("发送限额只能为 -1（不限制）、0（使用全局配置）或正数")
+	}
+	return nil
+}
+
+// AccountMailboxes 返回账户要处理的邮箱文件夹，未配置时为 INBOX
+func AccountMailboxes(account *models.Account) []string {
+	if account == nil || len(account.Folders) == 0 {
+		return []string{DefaultMailbox}
+	}
+	return account.Folders
+}
+
+// accountSearchFilter 返回账户附加的 Gmail 搜索条件
+func accountSearchFilter(account *models.Account) gmail.SearchFilter {
+	return gmail.SearchFilter{Labels: account.GmailLabels, Raw: account.GmailSearch}
+}
+
+// NewAccountProcessor 为账户创建独立的邮件处理器
+func NewAccountProcessor(account *models.Account) (*EmailProcessor, error) {
+	imapClient, smtpClient, err := NewAccountClients(account)
+	if err != nil {
+		return nil, err
+	}
+	ep := NewEmailProcessor(imapClient, smtpClient)
+	ep.account = account
+	return ep, nil
+}
+
+// NewDryRunProcessor 为账户创建只用于规则试运行的处理器，不需要认证信息，也不会连接服务器；
+// account 为空时使用全局配置，只使用未限定账户的规则
+//
+// 处理器没有 IMAP 客户端，SMTP 客户端只用于渲染转发邮件；ProcessEmails、Backfill 和转发后动作会返回错误或跳过。
+func NewDryRunProcessor(account *models.Account) *EmailProcessor {
+	cfg := config.GlobalConfig
+	if account == nil {
+		return NewEmailProcessor(nil, gmail.NewSMTPClient(cfg.SMTPServer(), cfg.GmailUser, ""))
+	}
+	smtpServer := overrideServer(cfg.SMTPServer(), account.SMTPHost, account.SMTPPort, account.SMTPTLSMode)
+	ep := NewEmailProcessor(nil, gmail.NewSMTPClient(smtpServer, account.Email, ""))
+	ep.account = account
+	return ep
+}
+
+// NewAccountClients 根据账户配置创建 IMAP/SMTP 客户端，服务器配置为空的字段使用全局配置，
+// IMAP 客户端的每次搜索都会附加账户的 Gmail 搜索条件
+//
+// OAuth2 认证时两个客户端共享同一个刷新令牌 TokenSource，访问令牌过期前自动刷新，
+// 刷新令牌保存在 oauth_tokens 表中（默认账户首次使用时取自 OAUTH2_REFRESH_TOKEN）。
+func NewAccountClients(account *models.Account) (*gmail.IMAPClient, *gmail.SMTPClient, error) {
+	cfg := config.GlobalConfig
+	imapServer := overrideServer(cfg.IMAPServer(), account.IMAPHost, account.IMAPPort, account.IMAPTLSMode)
+	smtpServer := overrideServer(cfg.SMTPServer(), account.SMTPHost, account.SMTPPort, account.SMTPTLSMode)
+
+	if account.AuthMode != models.AuthModeOAuth2 {
+		if account.Password == "" {
+			return nil, nil, fmt.Errorf("账户 %s 未设置密码", account.Email)
+		}
+		imapClient := gmail.NewIMAPClient(imapServer, account.Email, account.Password)
+		imapClient.SetSearchFilter(accountSearchFilter(account))
+		return imapClient, gmail.NewSMTPClient(smtpServer, account.Email, account.Password), nil
+	}
+
+	store := &dbTokenStore{account: account.Email}
+	if account.ID == 0 {
+		store.initialRefreshToken = cfg.OAuth2RefreshToken
+	}
+	tokens := gmail.NewRefreshTokenSource(cfg.OAuth2TokenURL, account.OAuth2ClientID, account.OAuth2ClientSecret, store)
+	imapClient := gmail.NewOAuth2IMAPClient(imapServer, account.Email, tokens)
+	imapClient.SetSearchFilter(accountSearchFilter(account))
+	return imapClient, gmail.NewOAuth2SMTPClient(smtpServer, account.Email, tokens), nil
+}
+
+// overrideServer 使用账户的服务器设置覆盖全局配置
+func overrideServer(server gmail.ServerConfig, host, port, tlsMode string) gmail.ServerConfig {
+	if host != "" {
+		server.Host = host
+	}
+	if port != "" {
+		server.Port = port
+	}
+	if tlsMode != "" {
+		server.TLSMode = gmail.TLSMode(tlsMode)
+	}
+	return server
+}
+
+// StoreRefreshToken 保存账户的 OAuth2 刷新令牌，旧的访问令牌随之失效
+func StoreRefreshToken(account, refreshToken string) error {
+	store := &dbTokenStore{account: account}
+	return store.SaveToken(&gmail.Token{RefreshToken: refreshToken})
+}
+
+// dbTokenStore 将账户的 OAuth2 令牌保存在数据库中
+type dbTokenStore struct {
+	account             string
+	initialRefreshToken string
+}
+
+// LoadToken 读取保存的令牌，尚未保存时使用配置的初始刷新令牌
+func (s *dbTokenStore) LoadToken() (*gmail.Token, error) {
+	db := database.GetDB()
+	var record models.OAuthToken
+
+	err := db.Where("account = ?", s.account).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if s.initialRefreshToken == "" {
+			return nil, fmt.Errorf("账户 %s 没有保存的刷新令牌", s.account)
+		}
+		return &gmail.Token{RefreshToken: s.initialRefreshToken}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询OAuth2令牌失败: %w", err)
+	}
+
+	token := &gmail.Token{
+		AccessToken:  record.AccessToken,
+		RefreshToken: record.RefreshToken,
+	}
+	if record.Expiry != nil {
+		token.Expiry = *record.Expiry
+	}
+	return token, nil
+}
+
+// SaveToken 保存刷新后的令牌
+func (s *dbTokenStore) SaveToken(token *gmail.Token) error {
+	db := database.GetDB()
+	record := models.OAuthToken{Account: s.account}
+
+	if err := db.Where("account = ?", s.account).FirstOrInit(&record).Error; err != nil {
+		return fmt.Errorf("加载OAuth2令牌失败: %w", err)
+	}
+
+	expiry := token.Expiry
+	record.RefreshToken = token.RefreshToken
+	record.AccessToken = token.AccessToken
+	record.Expiry = &expiry
+
+	if err := db.Save(&record).Error; err != nil {
+		return fmt.Errorf("保存OAuth2令牌失败: %w", err)
+	}
+	return nil
+}
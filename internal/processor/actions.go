@@ -0,0 +1,216 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+
+	"github.com/emersion/go-imap"
+)
+
+// validateMailActions 校验转发后动作
+func validateMailActions(actions []models.MailAction) error {
+	for i, action := range actions {
+		switch action.Type {
+		case models.MailActionAddLabels, models.MailActionRemoveLabels:
+			if len(action.Labels) == 0 {
+				return fmt.Errorf("第 %d 个动作 %s 需要指定 labels", i+1, action.Type)
+			}
+			for _, label := range action.Labels {
+				if strings.TrimSpace(label) == "" {
+					return fmt.Errorf("第 %d 个动作的标签不能为空", i+1)
+				}
+			}
+		case models.MailActionMove:
+			if strings.TrimSpace(action.Folder) == "" {
+				return fmt.Errorf("第 %d 个动作 move 需要指定 folder", i+1)
+			}
+		case models.MailActionArchive, models.MailActionFlag, models.MailActionDelete:
+		default:
+			return fmt.Errorf("第 %d 个动作类型 %q 无效，可选 add_labels、remove_labels、move、archive、flag、delete", i+1, action.Type)
+		}
+	}
+	return nil
+}
+
+// applyMailActions 对当前选择邮箱中的邮件执行转发后动作
+//
+// 先执行标签、星标等不改变邮件位置的动作，最后执行第一个移动/归档/删除动作；
+// 邮件离开当前文件夹后无法再对其操作，其余移动类动作会被忽略。动作失败只记录日志。
+func (ep *EmailProcessor) applyMailActions(email *gmail.Email, actions []models.MailAction) {
+	if len(actions) == 0 {
+		return
+	}
+	if ep.imapClient == nil {
+		log.Printf("邮件 [%s] 的转发后动作未执行: %v", email.Subject, errNoIMAPClient)
+		return
+	}
+	if email.UID == 0 {
+		log.Printf("邮件 [%s] 没有UID，跳过转发后动作", email.Subject)
+		return
+	}
+
+	var terminal *models.MailAction
+	for i := range actions {
+		action := actions[i]
+		if action.Terminal() {
+			if terminal == nil {
+				terminal = &action
+			} else {
+				log.Printf("邮件 [%s] 已有 %s 动作，忽略 %s 动作", email.Subject, terminal.Type, action.Type)
+			}
+			continue
+		}
+		ep.applyMailAction(email, action)
+	}
+	if terminal != nil {
+		ep.applyMailAction(email, *terminal)
+	}
+}
+
+// applyMailAction 执行单个动作
+func (ep *EmailProcessor) applyMailAction(email *gmail.Email, action models.MailAction) {
+	var err error
+	switch action.Type {
+	case models.MailActionAddLabels:
+		err = ep.imapClient.AddLabels(email.UID, action.Labels)
+	case models.MailActionRemoveLabels:
+		err = ep.imapClient.RemoveLabels(email.UID, action.Labels)
+	case models.MailActionFlag:
+		err = ep.imapClient.AddFlags(email.UID, imap.FlaggedFlag)
+	case models.MailActionMove:
+		err = ep.imapClient.Move(email.UID, action.Folder)
+	case models.MailActionArchive:
+		err = ep.imapClient.Archive(email.UID, action.Folder)
+	case models.MailActionDelete:
+		err = ep.imapClient.Delete(email.UID)
+	default:
+		err = fmt.Errorf("未知的动作类型 %s", action.Type)
+	}
+
+	if err != nil {
+		log.Printf("执行转发后动作 %s 失败 [%s]: %v", action.Type, email.Subject, err)
+		return
+	}
+	log.Printf("已执行转发后动作 %s [%s]", action.Type, email.Subject)
+}
+
+// messageActions 处理单封邮件时收集的各规则转发后动作
+type messageActions struct {
+	rules []models.RuleActions
+	group *models.MailActionGroup // 有目标写入发送队列时的动作组
+}
+
+// add 记录规则的转发后动作，没有配置动作的规则忽略
+func (a *messageActions) add(rule models.RuleActions) {
+	if len(rule.SuccessActions) > 0 || len(rule.FailureActions) > 0 {
+		a.rules = append(a.rules, rule)
+	}
+}
+
+// actionGroup 返回邮件的动作组，第一个目标写入发送队列时创建（随发送任务一起保存）
+func (a *messageActions) actionGroup(accountID uint, key string, email *gmail.Email) *models.MailActionGroup {
+	if a.group == nil {
+		a.group = &models.MailActionGroup{
+			AccountID:  accountID,
+			MessageKey: key,
+			Subject:    email.Subject,
+			Mailbox:    email.Mailbox,
+			UID:        email.UID,
+			Status:     models.MailActionGroupCollecting,
+		}
+	}
+	return a.group
+}
+
+// finishMailActions 邮件处理完成后执行转发后动作
+//
+// 没有目标写入发送队列时立即执行；否则保存各规则的动作并将动作组交给发送队列，
+// 如果发送任务在此之前已经全部完成，由这里直接执行。
+func (ep *EmailProcessor) finishMailActions(email *gmail.Email, actions *messageActions) {
+	if actions == nil {
+		return
+	}
+	if actions.group == nil || actions.group.ID == 0 {
+		var list []models.MailAction
+		for _, rule := range actions.rules {
+			list = append(list, rule.Actions()...)
+		}
+		ep.applyMailActions(email, list)
+		return
+	}
+
+	actions.group.Rules = actions.rules
+	actions.group.Status = models.MailActionGroupPending
+	db := database.GetDB()
+	if err := db.Model(actions.group).Select("rules", "status").Updates(actions.group).Error; err != nil {
+		log.Printf("保存转发后动作失败 [%s]: %v", email.Subject, err)
+		return
+	}
+
+	group, list, err := claimActionGroup(actions.group.ID)
+	if err != nil {
+		log.Printf("检查转发后动作失败 [%s]: %v", email.Subject, err)
+		return
+	}
+	if group != nil {
+		ep.applyMailActions(email, list)
+	}
+}
+
+// claimActionGroup 原邮件的所有发送任务都已完成时认领动作组，返回动作组和按最终结果要执行的动作；
+// 还有未完成的任务或已被认领时返回 nil。同一动作组只会被认领一次。
+func claimActionGroup(id uint) (*models.MailActionGroup, []models.MailAction, error) {
+	db := database.GetDB()
+
+	var open int64
+	err := db.Model(&models.OutboxJob{}).
+		Where("action_group_id = ? AND status IN ?", id, []string{models.OutboxStatusPending, models.OutboxStatusSending}).
+		Count(&open).Error
+	if err != nil || open > 0 {
+		return nil, nil, err
+	}
+
+	result := db.Model(&models.MailActionGroup{}).
+		Where("id = ? AND status = ?", id, models.MailActionGroupPending).
+		Update("status", models.MailActionGroupDone)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, result.Error
+	}
+
+	var group models.MailActionGroup
+	if err := db.First(&group, id).Error; err != nil {
+		return nil, nil, err
+	}
+	var jobs []models.OutboxJob
+	if err := db.Select("rule_id", "status").Where("action_group_id = ?", id).Find(&jobs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &group, mergeGroupActions(group.Rules, jobs), nil
+}
+
+// mergeGroupActions 将发送任务的最终结果合并到各规则处理时已确定的结果中，返回要执行的动作：
+// 进入死信为失败，发送成功为成功，丢弃的任务不计入
+func mergeGroupActions(rules []models.RuleActions, jobs []models.OutboxJob) []models.MailAction {
+	var actions []models.MailAction
+	for _, rule := range rules {
+		for _, job := range jobs {
+			if job.RuleID == nil || *job.RuleID != rule.RuleID {
+				continue
+			}
+			switch job.Status {
+			case models.OutboxStatusSent:
+				rule.Succeeded = true
+			case models.OutboxStatusDead:
+				rule.Failed = true
+			}
+		}
+		actions = append(actions, rule.Actions()...)
+	}
+	return actions
+}
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"gmail-forwarding/internal/models"
+)
+
+func TestMergeGroupActions(t *testing.T) {
+	archive := models.MailAction{Type: models.MailActionArchive}
+	label := models.MailAction{Type: models.MailActionAddLabels, Labels: []string{"Forwarded"}}
+	flag := models.MailAction{Type: models.MailActionFlag}
+
+	rule := func(id uint) models.RuleActions {
+		return models.RuleActions{
+			RuleID:         id,
+			SuccessActions: []models.MailAction{label, archive},
+			FailureActions: []models.MailAction{flag},
+		}
+	}
+	job := func(ruleID uint, status string) models.OutboxJob {
+		return models.OutboxJob{RuleID: &ruleID, Status: status}
+	}
+
+	tests := []struct {
+		name  string
+		rules []models.RuleActions
+		jobs  []models.OutboxJob
+		want  []models.MailAction
+	}{
+		{
+			name:  "all sent",
+			rules: []models.RuleActions{rule(1)},
+			jobs:  []models.OutboxJob{job(1, models.OutboxStatusSent), job(1, models.OutboxStatusSent)},
+			want:  []models.MailAction{label, archive},
+		},
+		{
+			name:  "one target dead",
+			rules: []models.RuleActions{rule(1)},
+			jobs:  []models.OutboxJob{job(1, models.OutboxStatusSent), job(1, models.OutboxStatusDead)},
+			want:  []models.MailAction{flag},
+		},
+		{
+			name:  "all discarded",
+			rules: []models.RuleActions{rule(1)},
+			jobs:  []models.OutboxJob{job(1, models.OutboxStatusDiscarded)},
+		},
+		{
+			name:  "discarded does not count",
+			rules: []models.RuleActions{rule(1)},
+			jobs:  []models.OutboxJob{job(1, models.OutboxStatusDiscarded), job(1, models.OutboxStatusSent)},
+			want:  []models.MailAction{label, archive},
+		},
+		{
+			name: "failed at processing time",
+			rules: []models.RuleActions{func() models.RuleActions {
+				r := rule(1)
+				r.Failed = true
+				return r
+			}()},
+			jobs: []models.OutboxJob{job(1, models.OutboxStatusSent)},
+			want: []models.MailAction{flag},
+		},
+		{
+			name: "already forwarded at processing time",
+			rules: []models.RuleActions{func() models.RuleActions {
+				r := rule(1)
+				r.Succeeded = true
+				return r
+			}()},
+			jobs: []models.OutboxJob{job(1, models.OutboxStatusDiscarded)},
+			want: []models.MailAction{label, archive},
+		},
+		{
+			name:  "rules merged separately",
+			rules: []models.RuleActions{rule(1), rule(2)},
+			jobs:  []models.OutboxJob{job(1, models.OutboxStatusDead), job(2, models.OutboxStatusSent)},
+			want:  []models.MailAction{flag, label, archive},
+		},
+		{
+			name:  "job without rule ignored",
+			rules: []models.RuleActions{rule(1)},
+			jobs:  []models.OutboxJob{{Status: models.OutboxStatusDead}, job(1, models.OutboxStatusSent)},
+			want:  []models.MailAction{label, archive},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeGroupActions(tt.rules, tt.jobs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeGroupActions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// backfillBatchSize 回溯处理时每批获取的邮件数量
+const backfillBatchSize = 50
+
+// BackfillOptions 回溯处理参数
+//
+// Since/Before 对应 IMAP SINCE/BEFORE 搜索条件（按邮件到达日期，精确到天），零值表示不限制。
+type BackfillOptions struct {
+	Mailbox string
+	Since   time.Time
+	Before  time.Time
+	DryRun  bool
+}
+
+// BackfillProgress 回溯处理进度
+type BackfillProgress struct {
+	Mailbox      string                `json:"mailbox"`
+	DryRun       bool                  `json:"dry_run"`
+	Total        int                   `json:"total"`
+	Processed    int                   `json:"processed"`
+	Failed       int                   `json:"failed"`
+	WouldForward int                   `json:"would_forward,omitempty"` // 仅试运行：将会转发的目标数量
+	Results      []BackfillEmailResult `json:"results,omitempty"`       // 仅试运行：命中规则的邮件
+}
+
+// BackfillEmailResult 试运行模式下单封命中规则邮件的评估结果
+type BackfillEmailResult struct {
+	MessageID  string           `json:"message_id"`
+	Subject    string           `json:"subject"`
+	Date       time.Time        `json:"date"`
+	Deliveries []DryRunDelivery `json:"deliveries"`
+}
+
+// ParseBackfillDate 解析回溯处理的日期参数（YYYY-MM-DD），空字符串返回零值
+func ParseBackfillDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日期格式不正确，应为 YYYY-MM-DD: %s", value)
+	}
+	return t, nil
+}
+
+// Validate 检查回溯处理参数
+func (o *BackfillOptions) Validate() error {
+	if o.Since.IsZero() && o.Before.IsZero() {
+		return fmt.Errorf("必须至少指定 since 或 before")
+	}
+	if !o.Since.IsZero() && !o.Before.IsZero() && !o.Since.Before(o.Before) {
+		return fmt.Errorf("since 必须早于 before")
+	}
+	return nil
+}
+
+// Backfill 按日期范围搜索邮箱中的历史邮件（未指定邮箱时为账户的第一个文件夹），并按当前启用的规则重新处理
+//
+// 回溯处理以只读方式选择邮箱，不修改邮件的已读状态、不执行规则的转发后动作，也不推进增量同步位置；
+// 已转发过的邮件由去重记录跳过，转发邮件写入发送队列，由服务进程的发送队列发送。试运行模式只评估规则，不发送邮件、不写入转发日志。
+// 每处理完一批邮件调用一次 progress（可以为空）。ctx 取消时在当前批次处理完后停止。
+func (ep *EmailProcessor) Backfill(ctx context.Context, opts BackfillOptions, progress func(BackfillProgress)) (*BackfillProgress, error) {
+	if ep.imapClient == nil {
+		return nil, errNoIMAPClient
+	}
+	if opts.Mailbox == "" {
+		opts.Mailbox = AccountMailboxes(ep.account)[0]
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.backfill = true
+	defer func() { ep.backfill = false }()
+
+	log.Printf("开始回溯处理邮箱 %s (试运行: %v)...", opts.Mailbox, opts.DryRun)
+
+	rs, err := ep.loadRuleSet(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ep.imapClient.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("连接IMAP服务器失败: %w", err)
+	}
+	defer ep.imapClient.Disconnect()
+
+	uids, err := ep.imapClient.SearchByDate(opts.Mailbox, opts.Since, opts.Before)
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件失败: %w", err)
+	}
+
+	state := &BackfillProgress{
+		Mailbox: opts.Mailbox,
+		DryRun:  opts.DryRun,
+		Total:   len(uids),
+	}
+	report := func() {
+		if progress != nil {
+			snapshot := *state
+			snapshot.Results = append([]BackfillEmailResult(nil), state.Results...)
+			progress(snapshot)
+		}
+	}
+	report()
+
+	for start := 0; start < len(uids); start += backfillBatchSize {
+		if err := ctx.Err(); err != nil {
+			return state, fmt.Errorf("回溯处理已取消: %w", err)
+		}
+		end := start + backfillBatchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+
+		emails, err := ep.imapClient.FetchByUIDs(uids[start:end])
+		if err != nil {
+			return state, fmt.Errorf("获取邮件失败: %w", err)
+		}
+
+		for _, email := range emails {
+			if opts.DryRun {
+				ep.backfillDryRun(email, rs, state)
+			} else if _, err := ep.processEmailWithRules(email, rs); err != nil {
+				log.Printf("处理邮件失败 [%s]: %v", email.Subject, err)
+				state.Failed++
+			}
+		}
+		// 解析失败的邮件不会返回，按批次计数保证进度能到达总数
+		state.Processed = end
+
+		log.Printf("回溯处理进度: %d/%d", state.Processed, state.Total)
+		report()
+	}
+
+	log.Printf("回溯处理完成: 共 %d 封邮件，失败 %d 封", state.Total, state.Failed)
+	return state, nil
+}
+
+// backfillDryRun 试运行单封邮件并汇总结果
+func (ep *EmailProcessor) backfillDryRun(email *gmail.Email, rs *ruleSet, state *BackfillProgress) {
+	result, err := ep.dryRunWithRules(email, rs)
+	if err != nil {
+		log.Printf("试运行邮件失败 [%s]: %v", email.Subject, err)
+		state.Failed++
+		return
+	}
+	if len(result.MatchedRules) == 0 {
+		return
+	}
+
+	entry := BackfillEmailResult{
+		MessageID: messageKey(email),
+		Subject:   email.Subject,
+		Date:      email.Date,
+	}
+	for _, d := range result.Deliveries {
+		if d.Status == models.ForwardStatusForwarded {
+			state.WouldForward++
+		}
+		d.Message = "" // 批量结果中不返回邮件内容
+		entry.Deliveries = append(entry.Deliveries, d)
+	}
+	state.Results = append(state.Results, entry)
+}
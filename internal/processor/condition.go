@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// maxConditionDepth 条件树最大嵌套深度
+const maxConditionDepth = 10
+
+// compileCondition 将条件树编译为匹配函数
+func compileCondition(cond *models.Condition) (func(email *gmail.Email) bool, error) {
+	return compileConditionNode(cond, 1)
+}
+
+// compileConditionNode 递归编译条件树节点
+func compileConditionNode(cond *models.Condition, depth int) (func(email *gmail.Email) bool, error) {
+	if depth > maxConditionDepth {
+		return nil, fmt.Errorf("条件嵌套超过 %d 层", maxConditionDepth)
+	}
+
+	if cond.Op == "" {
+		return compileLeafCondition(cond)
+	}
+
+	children := make([]func(*gmail.Email) bool, 0, len(cond.Conditions))
+	for i := range cond.Conditions {
+		child, err := compileConditionNode(&cond.Conditions[i], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	switch strings.ToLower(cond.Op) {
+	case models.ConditionOpAnd:
+		if len(children) == 0 {
+			return nil, fmt.Errorf("and 条件至少需要一个子条件")
+		}
+		return func(email *gmail.Email) bool {
+			for _, child := range children {
+				if !child(email) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case models.ConditionOpOr:
+		if len(children) == 0 {
+			return nil, fmt.Errorf("or 条件至少需要一个子条件")
+		}
+		return func(email *gmail.Email) bool {
+			for _, child := range children {
+				if child(email) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case models.ConditionOpNot:
+		if len(children) != 1 {
+			return nil, fmt.Errorf("not 条件只能有一个子条件")
+		}
+		return func(email *gmail.Email) bool { return !children[0](email) }, nil
+	default:
+		return nil, fmt.Errorf("不支持的条件组合方式: %s", cond.Op)
+	}
+}
+
+// compileLeafCondition 编译叶子条件：任一字段值满足匹配即为真
+func compileLeafCondition(cond *models.Condition) (func(email *gmail.Email) bool, error) {
+	if len(cond.Conditions) > 0 {
+		return nil, fmt.Errorf("叶子条件不能包含子条件，请设置 op")
+	}
+
+	values, err := conditionValues(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	matchType := cond.MatchType
+	if matchType == "" {
+		matchType = models.MatchTypeExact
+	}
+
+	if matchType == models.MatchTypeExists {
+		return func(email *gmail.Email) bool {
+			for _, v := range values(email) {
+				if strings.TrimSpace(v) != "" {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	// 地址类字段默认忽略大小写
+	ignoreCase := cond.IgnoreCase
+	switch cond.Field {
+	case models.ConditionFieldFrom, models.ConditionFieldFromDomain, models.ConditionFieldTo, models.ConditionFieldCc:
+		ignoreCase = true
+	}
+
+	match, err := compilePattern(matchType, cond.Value, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(email *gmail.Email) bool {
+		for _, v := range values(email) {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// conditionValues 根据条件字段返回取值函数
+func conditionValues(cond *models.Condition) (func(email *gmail.Email) []string, error) {
+	switch cond.Field {
+	case models.ConditionFieldSubject:
+		return func(email *gmail.Email) []string { return []string{email.Subject} }, nil
+	case models.ConditionFieldFrom:
+		return func(email *gmail.Email) []string { return []string{email.FromAddress} }, nil
+	case models.ConditionFieldFromDomain:
+		return func(email *gmail.Email) []string {
+			return []string{email.FromAddress[strings.LastIndex(email.FromAddress, "@")+1:]}
+		}, nil
+	case models.ConditionFieldTo:
+		return func(email *gmail.Email) []string { return email.ToAddresses }, nil
+	case models.ConditionFieldCc:
+		return func(email *gmail.Email) []string { return email.CcAddresses }, nil
+	case models.ConditionFieldHeader:
+		if cond.Header == "" {
+			return nil, fmt.Errorf("header 条件必须指定邮件头名称")
+		}
+		key := textproto.CanonicalMIMEHeaderKey(cond.Header)
+		return func(email *gmail.Email) []string { return email.Header[key] }, nil
+	case models.ConditionFieldBody:
+		return func(email *gmail.Email) []string { return []string{email.Body} }, nil
+	case models.ConditionFieldHTML:
+		return func(email *gmail.Email) []string { return []string{email.HTML} }, nil
+	default:
+		return nil, fmt.Errorf("不支持的条件字段: %s", cond.Field)
+	}
+}
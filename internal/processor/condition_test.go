@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// leaf 创建叶子条件
+func leaf(field, matchType, value string) models.Condition {
+	return models.Condition{Field: field, MatchType: matchType, Value: value}
+}
+
+// nested 创建 depth 层嵌套的 not 条件，最内层为叶子条件
+func nested(depth int) models.Condition {
+	cond := leaf(models.ConditionFieldSubject, models.MatchTypeContains, "加急")
+	for i := 1; i < depth; i++ {
+		cond = models.Condition{Op: models.ConditionOpNot, Conditions: []models.Condition{cond}}
+	}
+	return cond
+}
+
+func TestCompileCondition(t *testing.T) {
+	email := &gmail.Email{
+		Subject:     "订单通知 - 加急",
+		FromAddress: "Alice@Example.com",
+		ToAddresses: []string{"ops@example.org", "team@example.org"},
+		Header: textproto.MIMEHeader{
+			"X-Priority": {"1 (Highest)"},
+			"List-Id":    {""},
+		},
+		Body: "order #42 shipped",
+	}
+
+	tests := []struct {
+		name string
+		cond models.Condition
+		want bool
+	}{
+		{name: "leaf exact default", cond: models.Condition{Field: models.ConditionFieldSubject, Value: "订单通知 - 加急"}, want: true},
+		{name: "from ignores case", cond: leaf(models.ConditionFieldFrom, models.MatchTypeExact, "alice@example.com"), want: true},
+		{name: "from domain", cond: leaf(models.ConditionFieldFromDomain, models.MatchTypeExact, "EXAMPLE.COM"), want: true},
+		{name: "any recipient", cond: leaf(models.ConditionFieldTo, models.MatchTypePrefix, "team@"), want: true},
+		{name: "no cc", cond: leaf(models.ConditionFieldCc, models.MatchTypeContains, "@"), want: false},
+		{name: "header prefix", cond: models.Condition{Field: models.ConditionFieldHeader, Header: "x-priority", MatchType: models.MatchTypePrefix, Value: "1"}, want: true},
+		{name: "header exists", cond: models.Condition{Field: models.ConditionFieldHeader, Header: "X-Priority", MatchType: models.MatchTypeExists}, want: true},
+		{name: "empty header does not exist", cond: models.Condition{Field: models.ConditionFieldHeader, Header: "List-Id", MatchType: models.MatchTypeExists}, want: false},
+		{name: "body regex", cond: leaf(models.ConditionFieldBody, models.MatchTypeRegex, `#\d+`), want: true},
+		{
+			name: "and",
+			cond: models.Condition{Op: models.ConditionOpAnd, Conditions: []models.Condition{
+				leaf(models.ConditionFieldFromDomain, models.MatchTypeExact, "example.com"),
+				leaf(models.ConditionFieldSubject, models.MatchTypeContains, "加急"),
+			}},
+			want: true,
+		},
+		{
+			name: "and with one false",
+			cond: models.Condition{Op: models.ConditionOpAnd, Conditions: []models.Condition{
+				leaf(models.ConditionFieldFromDomain, models.MatchTypeExact, "example.com"),
+				leaf(models.ConditionFieldSubject, models.MatchTypeContains, "退款"),
+			}},
+			want: false,
+		},
+		{
+			name: "or",
+			cond: models.Condition{Op: "OR", Conditions: []models.Condition{
+				leaf(models.ConditionFieldSubject, models.MatchTypeContains, "退款"),
+				leaf(models.ConditionFieldTo, models.MatchTypeExact, "ops@example.org"),
+			}},
+			want: true,
+		},
+		{
+			name: "not",
+			cond: models.Condition{Op: models.ConditionOpNot, Conditions: []models.Condition{
+				leaf(models.ConditionFieldFromDomain, models.MatchTypeExact, "example.com"),
+			}},
+			want: false,
+		},
+		{
+			name: "nested and/or/not",
+			cond: models.Condition{Op: models.ConditionOpAnd, Conditions: []models.Condition{
+				leaf(models.ConditionFieldFromDomain, models.MatchTypeExact, "example.com"),
+				{Op: models.ConditionOpOr, Conditions: []models.Condition{
+					{Field: models.ConditionFieldHeader, Header: "X-Priority", MatchType: models.MatchTypePrefix, Value: "5"},
+					{Op: models.ConditionOpNot, Conditions: []models.Condition{
+						leaf(models.ConditionFieldSubject, models.MatchTypeGlob, "*退款*"),
+					}},
+				}},
+			}},
+			want: true,
+		},
+		{name: "max depth", cond: nested(maxConditionDepth), want: maxConditionDepth%2 == 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := compileCondition(&tt.cond)
+			if err != nil {
+				t.Fatalf("compileCondition: %v", err)
+			}
+			if got := match(email); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileConditionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cond    models.Condition
+		wantErr string
+	}{
+		{name: "too deep", cond: nested(maxConditionDepth + 1), wantErr: "嵌套超过"},
+		{name: "unknown field", cond: leaf("sender", models.MatchTypeExact, "a"), wantErr: "不支持的条件字段"},
+		{name: "missing field", cond: models.Condition{Value: "a"}, wantErr: "不支持的条件字段"},
+		{name: "unknown match type", cond: leaf(models.ConditionFieldSubject, "like", "a"), wantErr: "不支持的匹配方式"},
+		{name: "invalid regex", cond: leaf(models.ConditionFieldSubject, models.MatchTypeRegex, "("), wantErr: "正则表达式无效"},
+		{name: "header without name", cond: leaf(models.ConditionFieldHeader, models.MatchTypeExists, ""), wantErr: "必须指定邮件头名称"},
+		{name: "unknown op", cond: models.Condition{Op: "xor", Conditions: []models.Condition{leaf(models.ConditionFieldSubject, "", "a")}}, wantErr: "不支持的条件组合方式"},
+		{name: "empty and", cond: models.Condition{Op: models.ConditionOpAnd}, wantErr: "and 条件至少需要一个子条件"},
+		{name: "empty or", cond: models.Condition{Op: models.ConditionOpOr}, wantErr: "or 条件至少需要一个子条件"},
+		{
+			name: "not with two children",
+			cond: models.Condition{Op: models.ConditionOpNot, Conditions: []models.Condition{
+				leaf(models.ConditionFieldSubject, "", "a"),
+				leaf(models.ConditionFieldSubject, "", "b"),
+			}},
+			wantErr: "not 条件只能有一个子条件",
+		},
+		{
+			name:    "leaf with children",
+			cond:    models.Condition{Field: models.ConditionFieldSubject, Conditions: []models.Condition{leaf(models.ConditionFieldSubject, "", "a")}},
+			wantErr: "叶子条件不能包含子条件",
+		},
+		{
+			name: "error in nested child",
+			cond: models.Condition{Op: models.ConditionOpOr, Conditions: []models.Condition{
+				leaf(models.ConditionFieldSubject, "", "a"),
+				{Op: models.ConditionOpNot, Conditions: []models.Condition{leaf("size", "", "1")}},
+			}},
+			wantErr: "不支持的条件字段: size",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileCondition(&tt.cond)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("compileCondition error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
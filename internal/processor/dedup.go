@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// messageKey 计算邮件的唯一标识，优先使用 Message-ID，
+// 缺失时使用发件人、收件人、主题和日期的哈希值
+func messageKey(email *gmail.Email) string {
+	if id := strings.TrimSpace(email.MessageID); id != "" {
+		return id
+	}
+
+	h := sha256.New()
+	for _, field := range []string{
+		email.From,
+		email.To,
+		email.Subject,
+		email.Date.UTC().Format(time.RFC3339),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// alreadyForwarded 检查邮件是否已经成功转发给指定邮箱
+func (ep *EmailProcessor) alreadyForwarded(key, targetEmail string) (bool, error) {
+	db := database.GetDB()
+	var record models.ForwardedMessage
+
+	err := db.Where("message_key = ? AND target_email = ?", key, targetEmail).First(&record).Error
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("查询转发记录失败: %w", err)
+	}
+	return false, nil
+}
+
+// alreadyQueued 检查邮件是否已在发送队列中（包括死信，需通过接口重试或丢弃）
+//
+// 这里只是提前检查；多个处理器并发处理同一封邮件时，由发送任务的去重键唯一索引保证只写入一个任务。
+func (ep *EmailProcessor) alreadyQueued(key, targetEmail string) (bool, error) {
+	db := database.GetDB()
+	var queued int64
+	err := db.Model(&models.OutboxJob{}).
+		Where("message_key = ? AND target_email = ? AND status IN ?", key, targetEmail,
+			[]string{models.OutboxStatusPending, models.OutboxStatusSending, models.OutboxStatusDead}).
+		Count(&queued).Error
+	if err != nil {
+		return false, fmt.Errorf("查询发送队列失败: %w", err)
+	}
+	return queued > 0, nil
+}
+
+// outboxDedupKey 计算发送任务的去重键，同一封邮件的同一转发目标只能有一个未丢弃的任务
+func outboxDedupKey(key, targetEmail string) *string {
+	sum := sha256.Sum256([]byte(key + "\x00" + strings.ToLower(targetEmail)))
+	dedupKey := hex.EncodeToString(sum[:])
+	return &dedupKey
+}
+
+// markForwarded 记录邮件已转发给指定邮箱
+func markForwarded(key, targetEmail string) error {
+	db := database.GetDB()
+	record := models.ForwardedMessage{
+		MessageKey:  key,
+		TargetEmail: targetEmail,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return fmt.Errorf("保存转发记录失败: %w", err)
+	}
+	return nil
+}
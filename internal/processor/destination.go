@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"fmt"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+)
+
+// destinationPolicy 转发目标白名单/黑名单策略
+//
+// 黑名单优先；配置了白名单时，目标地址必须命中白名单；未配置白名单时允许黑名单以外的所有地址。
+type destinationPolicy struct {
+	allow []models.DestinationFilter
+	deny  []models.DestinationFilter
+}
+
+// loadDestinationPolicy 预加载所有启用的转发目标过滤规则
+func (ep *EmailProcessor) loadDestinationPolicy() (*destinationPolicy, error) {
+	db := database.GetDB()
+	var filters []models.DestinationFilter
+
+	if err := db.Where("active = ?", true).Find(&filters).Error; err != nil {
+		return nil, fmt.Errorf("加载转发目标过滤规则失败: %w", err)
+	}
+
+	policy := &destinationPolicy{}
+	for _, f := range filters {
+		if f.Type == models.FilterTypeDeny {
+			policy.deny = append(policy.deny, f)
+		} else {
+			policy.allow = append(policy.allow, f)
+		}
+	}
+	return policy, nil
+}
+
+// check 检查目标地址是否允许转发，不允许时返回原因
+func (p *destinationPolicy) check(address string) error {
+	for _, f := range p.deny {
+		if f.Matches(address) {
+			return fmt.Errorf("目标地址 %s 命中黑名单 %s", address, f.Pattern)
+		}
+	}
+
+	if len(p.allow) == 0 {
+		return nil
+	}
+	for _, f := range p.allow {
+		if f.Matches(address) {
+			return nil
+		}
+	}
+	return fmt.Errorf("目标地址 %s 不在白名单中", address)
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// DryRunResult 规则试运行结果
+type DryRunResult struct {
+	Parse        *SubjectParseResult `json:"parse"`
+	ParseError   string              `json:"parse_error,omitempty"`
+	Trace        []models.RuleTrace  `json:"trace"`
+	MatchedRules []uint              `json:"matched_rules"`
+	Skipped      string              `json:"skipped,omitempty"` // 整封邮件不转发的原因
+	Deliveries   []DryRunDelivery    `json:"deliveries"`
+}
+
+// DryRunDelivery 试运行中单个转发目标的处理结果
+type DryRunDelivery struct {
+	RuleID      uint   `json:"rule_id"`
+	Keyword     string `json:"keyword"`
+	TargetEmail string `json:"target_email,omitempty"`
+	ForwardMode string `json:"forward_mode"`
+	Status      string `json:"status"` // 与转发日志状态一致，forwarded 表示将会转发
+	Error       string `json:"error,omitempty"`
+	Message     string `json:"message,omitempty"` // 将要发送的转发邮件内容
+}
+
+// DryRun 按实际处理流程评估邮件会如何转发，但不连接 IMAP/SMTP，也不写入转发日志和转发记录
+//
+// includeInactive 为 true 时未启用的规则也参与匹配，便于在启用规则前验证其效果。
+func (ep *EmailProcessor) DryRun(email *gmail.Email, includeInactive bool) (*DryRunResult, error) {
+	rs, err := ep.loadRuleSet(includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	return ep.dryRunWithRules(email, rs)
+}
+
+// dryRunWithRules 使用预加载规则试运行单封邮件
+func (ep *EmailProcessor) dryRunWithRules(email *gmail.Email, rs *ruleSet) (*DryRunResult, error) {
+	match, err := ep.shouldForward(email, rs.rules)
+	result := &DryRunResult{
+		Parse:        match.parse,
+		Trace:        match.trace,
+		MatchedRules: []uint{},
+		Deliveries:   []DryRunDelivery{},
+	}
+	if _, parseErr := ep.parseSubject(email.Subject); parseErr != nil {
+		result.ParseError = parseErr.Error()
+	}
+	if err != nil {
+		result.Skipped = err.Error()
+		return result, nil
+	}
+
+	key := messageKey(email)
+	seen := make(map[string]bool)
+	for _, rule := range match.rules {
+		result.MatchedRules = append(result.MatchedRules, rule.ID)
+		delivery := DryRunDelivery{
+			RuleID:      rule.ID,
+			Keyword:     rule.Keyword,
+			ForwardMode: string(forwardMode(rule)),
+		}
+
+		if err := rs.senders.check(email, rule); err != nil {
+			delivery.Status = models.ForwardStatusUnauthorized
+			delivery.Error = err.Error()
+			result.Deliveries = append(result.Deliveries, delivery)
+			continue
+		}
+
+		targets := forwardTargets(match.parse, rule)
+		if len(targets) == 0 {
+			delivery.Status = models.ForwardStatusSkipped
+			delivery.Error = "邮件主题中没有邮箱地址，规则也未绑定固定收件人"
+			result.Deliveries = append(result.Deliveries, delivery)
+			continue
+		}
+
+		for _, target := range targets {
+			if seen[strings.ToLower(target)] {
+				continue
+			}
+			seen[strings.ToLower(target)] = true
+
+			d := delivery
+			d.TargetEmail = target
+			if err := ep.dryRunTarget(email, key, rule, rs, &d); err != nil {
+				return nil, err
+			}
+			result.Deliveries = append(result.Deliveries, d)
+		}
+	}
+	return result, nil
+}
+
+// dryRunTarget 评估单个转发目标，与 forwardTo 的检查顺序一致
+func (ep *EmailProcessor) dryRunTarget(email *gmail.Email, key string, rule *models.ForwardingRule, rs *ruleSet, d *DryRunDelivery) error {
+	if err := rs.destinations.check(d.TargetEmail); err != nil {
+		d.Status = models.ForwardStatusRejected
+		d.Error = err.Error()
+		return nil
+	}
+
+	forwarded, err := ep.alreadyForwarded(key, d.TargetEmail)
+	if err != nil {
+		return fmt.Errorf("检查重复转发失败: %w", err)
+	}
+	if forwarded {
+		d.Status = models.ForwardStatusDuplicate
+		d.Error = "邮件已转发过，跳过重复转发"
+		return nil
+	}
+	queued, err := ep.alreadyQueued(key, d.TargetEmail)
+	if err != nil {
+		return fmt.Errorf("检查发送队列失败: %w", err)
+	}
+	if queued {
+		d.Status = models.ForwardStatusDuplicate
+		d.Error = "邮件已在发送队列中，跳过重复转发"
+		return nil
+	}
+
+	d.Status = models.ForwardStatusForwarded
+	d.Message = ep.smtpClient.RenderForwardMessage(email, d.TargetEmail, forwardMode(rule))
+	return nil
+}
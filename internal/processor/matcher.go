@@ -0,0 +1,215 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// keywordMatcher 预编译的规则匹配器
+//
+// 规则按优先级（Priority 升序，相同时按 ID）依次匹配，一封邮件可以命中多条规则，
+// 命中设置了 StopProcessing 的规则后不再匹配后续规则。
+// 规则配置了条件树时，关键字匹配后还需满足条件才算命中。
+type keywordMatcher struct {
+	rules []compiledRule
+}
+
+// compiledRule 编译后的规则
+type compiledRule struct {
+	rule      *models.ForwardingRule
+	keyword   func(keyword string) bool
+	condition func(email *gmail.Email) bool // 为空表示没有附加条件
+}
+
+// matches 检查关键字和邮件是否满足规则
+func (c compiledRule) matches(keyword string, email *gmail.Email) bool {
+	if !c.keyword(keyword) {
+		return false
+	}
+	return c.condition == nil || c.condition(email)
+}
+
+// newKeywordMatcher 按优先级编译规则列表，无效的规则记录错误后跳过
+func newKeywordMatcher(rules []models.ForwardingRule) (*keywordMatcher, []error) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].ID < rules[j].ID
+	})
+
+	m := &keywordMatcher{}
+	var errs []error
+
+	for i := range rules {
+		rule := &rules[i]
+		compiled, err := compileRule(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("规则 %d (%s): %w", rule.ID, rule.Keyword, err))
+			continue
+		}
+		m.rules = append(m.rules, compiled)
+	}
+	return m, errs
+}
+
+// size 返回有效规则数量
+func (m *keywordMatcher) size() int {
+	return len(m.rules)
+}
+
+// matchAll 按优先级返回所有命中的规则以及每条被评估规则的匹配记录
+func (m *keywordMatcher) matchAll(keyword string, email *gmail.Email) ([]*models.ForwardingRule, []models.RuleTrace) {
+	var matched []*models.ForwardingRule
+	trace := make([]models.RuleTrace, 0, len(m.rules))
+
+	for _, c := range m.rules {
+		ok := c.matches(keyword, email)
+		trace = append(trace, models.RuleTrace{
+			RuleID:   c.rule.ID,
+			Keyword:  c.rule.Keyword,
+			Priority: c.rule.Priority,
+			Matched:  ok,
+			Stop:     ok && c.rule.StopProcessing,
+		})
+		if !ok {
+			continue
+		}
+
+		matched = append(matched, c.rule)
+		if c.rule.StopProcessing {
+			break
+		}
+	}
+	return matched, trace
+}
+
+// matchType 获取规则的匹配方式，未设置时为精确匹配
+func matchType(rule *models.ForwardingRule) string {
+	if rule.MatchType == "" {
+		return models.MatchTypeExact
+	}
+	return rule.MatchType
+}
+
+// ValidateRule 校验规则的匹配方式、模式、条件树和转发后动作是否有效
+func ValidateRule(rule *models.ForwardingRule) error {
+	if _, err := compileRule(rule); err != nil {
+		return err
+	}
+	if err := validateMailActions(rule.SuccessActions); err != nil {
+		return fmt.Errorf("转发成功动作无效: %w", err)
+	}
+	if err := validateMailActions(rule.FailureActions); err != nil {
+		return fmt.Errorf("转发失败动作无效: %w", err)
+	}
+	return nil
+}
+
+// compileRule 编译规则的关键字模式和条件树
+func compileRule(rule *models.ForwardingRule) (compiledRule, error) {
+	if rule.Keyword == "" {
+		return compiledRule{}, fmt.Errorf("关键字不能为空")
+	}
+
+	compiled := compiledRule{rule: rule}
+	if matchType(rule) == models.MatchTypeAny {
+		compiled.keyword = func(string) bool { return true }
+	} else {
+		match, err := compilePattern(matchType(rule), rule.Keyword, rule.IgnoreCase)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		compiled.keyword = match
+	}
+
+	if rule.Conditions != nil {
+		condition, err := compileCondition(rule.Conditions)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("条件无效: %w", err)
+		}
+		compiled.condition = condition
+	}
+
+	return compiled, nil
+}
+
+// compilePattern 将匹配方式和模式编译为匹配函数
+func compilePattern(matchType, pattern string, ignoreCase bool) (func(value string) bool, error) {
+	expected := pattern
+	fold := func(s string) string { return s }
+	if ignoreCase {
+		fold = strings.ToLower
+		expected = strings.ToLower(pattern)
+	}
+
+	switch matchType {
+	case models.MatchTypeExact:
+		return func(value string) bool { return fold(value) == expected }, nil
+	case models.MatchTypePrefix:
+		return func(value string) bool { return strings.HasPrefix(fold(value), expected) }, nil
+	case models.MatchTypeContains:
+		return func(value string) bool { return strings.Contains(fold(value), expected) }, nil
+	case models.MatchTypeRegex:
+		expr := pattern
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %w", err)
+		}
+		return re.MatchString, nil
+	case models.MatchTypeGlob:
+		re, err := globToRegexp(pattern, ignoreCase)
+		if err != nil {
+			return nil, fmt.Errorf("通配符模式无效: %w", err)
+		}
+		return re.MatchString, nil
+	default:
+		return nil, fmt.Errorf("不支持的匹配方式: %s", matchType)
+	}
+}
+
+// globToRegexp 将通配符模式转换为正则表达式，* 匹配任意字符串，? 匹配单个字符，[...] 匹配字符集
+func globToRegexp(glob string, ignoreCase bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if ignoreCase {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("字符集缺少结束符 ]")
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,665 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"gmail-forwarding/internal/config"
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 发送队列默认配置
+const (
+	defaultOutboxWorkers      = 2
+	defaultOutboxMaxAttempts  = 8
+	defaultOutboxRetryBase    = 30 * time.Second
+	defaultOutboxRetryMax     = time.Hour
+	defaultOutboxPollInterval = 5 * time.Second
+
+	// outboxBatchSize 每次轮询最多取出的待发送任务数
+	outboxBatchSize = 100
+
+	// outboxStaleSending 任务处于发送中超过该时间（远大于 SMTP 超时）视为认领后未能更新状态，重新放回队列
+	outboxStaleSending = 30 * time.Minute
+)
+
+// Outbox 持久化发送队列
+//
+// 邮件处理只将渲染好的转发邮件写入 outbox_jobs 表，由发送队列的后台协程发送；
+// 发送失败按指数退避（带抖动）重试，超过最大尝试次数后进入死信，可通过接口手动重试或丢弃。
+// 进程重启后未完成的任务会继续发送，发送过程中崩溃的任务可能会重复发送一次；
+// 认领后长时间处于发送中的任务（如认领后数据库不可用）也会重新放回队列。
+// 每个发送协程为各账户保持一个已认证的 SMTP 会话，连续发送时复用，空闲后关闭。
+// 发送前检查账户和收件人的发送限额，超出限额的任务推迟到有余量时发送，不计入尝试次数。
+// 认证失败、TLS 错误和发件人被拒绝是账户级错误，换一个任务也会失败：暂停该账户的所有待发送任务并按退避时间重试，
+// 不计入尝试次数，也不进入死信。
+type Outbox struct {
+	workers      int
+	maxAttempts  int
+	retryBase    time.Duration
+	retryMax     time.Duration
+	pollInterval time.Duration
+	limiter      *RateLimiter
+
+	pauseMu       sync.Mutex
+	accountPauses map[uint]int // 各账户连续遇到账户级错误的次数，发送成功后清零
+
+	ctx  context.Context // 发送和转发后动作使用的 context，取消时中断正在进行的发送
+	jobs chan uint
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewOutbox 根据配置创建发送队列，无效的配置使用默认值
+func NewOutbox() *Outbox {
+	cfg := config.GlobalConfig
+	return &Outbox{
+		workers:       parsePositiveInt("OUTBOX_WORKERS", cfg.OutboxWorkers, defaultOutboxWorkers),
+		maxAttempts:   parsePositiveInt("OUTBOX_MAX_ATTEMPTS", cfg.OutboxMaxAttempts, defaultOutboxMaxAttempts),
+		retryBase:     parsePositiveDuration("OUTBOX_RETRY_BASE", cfg.OutboxRetryBase, defaultOutboxRetryBase),
+		retryMax:      parsePositiveDuration("OUTBOX_RETRY_MAX", cfg.OutboxRetryMax, defaultOutboxRetryMax),
+		pollInterval:  parsePositiveDuration("OUTBOX_POLL_INTERVAL", cfg.OutboxPollInterval, defaultOutboxPollInterval),
+		limiter:       NewRateLimiter(),
+		accountPauses: make(map[uint]int),
+		jobs:          make(chan uint),
+		stop:          make(chan struct{}),
+	}
+}
+
+// parsePositiveInt 解析正整数配置，无效时使用默认值
+func parsePositiveInt(name, value string, defaultValue int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("无效的%s配置 %s，使用默认值 %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// parsePositiveDuration 解析正时长配置，无效时使用默认值
+func parsePositiveDuration(name, value string, defaultValue time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("无效的%s配置 %s，使用默认值 %s", name, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// Start 启动发送队列，上次退出时仍在发送中的任务重新放回队列；ctx 取消时中断正在进行的发送
+func (o *Outbox) Start(ctx context.Context) {
+	o.ctx = ctx
+
+	if n, err := recoverSendingJobs(time.Now()); err != nil {
+		log.Printf("恢复发送中的任务失败: %v", err)
+	} else if n > 0 {
+		log.Printf("恢复了 %d 个上次未完成发送的任务", n)
+	}
+
+	o.wg.Add(o.workers + 1)
+	go o.dispatchLoop()
+	for i := 0; i < o.workers; i++ {
+		go o.workLoop()
+	}
+	log.Printf("发送队列已启动: %d 个发送协程，最多尝试 %d 次", o.workers, o.maxAttempts)
+}
+
+// Stop 停止发送队列，等待正在发送的任务完成
+func (o *Outbox) Stop() {
+	close(o.stop)
+	o.wg.Wait()
+	log.Println("发送队列已停止")
+}
+
+// dispatchLoop 定时取出到期的待发送任务分发给发送协程
+func (o *Outbox) dispatchLoop() {
+	defer o.wg.Done()
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := recoverSendingJobs(time.Now().Add(-outboxStaleSending)); err != nil {
+			log.Printf("恢复发送中的任务失败: %v", err)
+		} else if n > 0 {
+			log.Printf("恢复了 %d 个长时间处于发送中的任务", n)
+		}
+
+		ids, err := dueOutboxJobs(outboxBatchSize)
+		if err != nil {
+			log.Printf("查询待发送任务失败: %v", err)
+		}
+		for _, id := range ids {
+			select {
+			case o.jobs <- id:
+			case <-o.stop:
+				return
+			}
+		}
+
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverSendingJobs 将 before 之前认领、仍处于发送中的任务放回队列立即发送，返回恢复的任务数
+func recoverSendingJobs(before time.Time) (int64, error) {
+	db := database.GetDB()
+	result := db.Model(&models.OutboxJob{}).
+		Where("status = ? AND updated_at < ?", models.OutboxStatusSending, before).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxStatusPending,
+			"next_attempt_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// dueOutboxJobs 查询已到发送时间的待发送任务
+func dueOutboxJobs(limit int) ([]uint, error) {
+	db := database.GetDB()
+	var ids []uint
+	err := db.Model(&models.OutboxJob{}).
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, time.Now()).
+		Order("next_attempt_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// workLoop 逐个发送分发的任务，每个发送协程为各账户保持独立的 SMTP 会话
+func (o *Outbox) workLoop() {
+	defer o.wg.Done()
+	senders := newSMTPSenders()
+	defer senders.closeAll()
+
+	ticker := time.NewTicker(gmail.SMTPSessionIdleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case id := <-o.jobs:
+			o.process(id, senders)
+		case <-ticker.C:
+			senders.closeIdle()
+		}
+	}
+}
+
+// process 认领并发送一个任务，同一任务只会被一个发送协程认领
+func (o *Outbox) process(id uint, senders *smtpSenders) {
+	db := database.GetDB()
+	result := db.Model(&models.OutboxJob{}).
+		Where("id = ? AND status = ?", id, models.OutboxStatusPending).
+		Update("status", models.OutboxStatusSending)
+	if result.Error != nil {
+		log.Printf("认领发送任务 %d 失败: %v", id, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return // 已被其他协程认领，或已被重试/丢弃
+	}
+
+	var job models.OutboxJob
+	if err := db.First(&job, id).Error; err != nil {
+		// 放回队列稍后重试，否则任务会一直处于发送中；放回也失败时由超时恢复处理
+		log.Printf("加载发送任务 %d 失败: %v", id, err)
+		err = db.Model(&models.OutboxJob{}).
+			Where("id = ? AND status = ?", id, models.OutboxStatusSending).
+			Updates(map[string]interface{}{
+				"status":          models.OutboxStatusPending,
+				"next_attempt_at": time.Now().Add(o.pollInterval),
+			}).Error
+		if err != nil {
+			log.Printf("更新发送任务 %d 失败: %v", id, err)
+		}
+		return
+	}
+
+	account, err := outboxAccount(job.AccountID)
+	if err == nil && o.throttle(account, &job) {
+		return
+	}
+	if err == nil {
+		err = senders.send(o.ctx, account, &job)
+	}
+	if err != nil && o.ctx.Err() != nil {
+		// 服务关闭中断的发送不计入尝试次数，下次启动时重新发送
+		log.Printf("发送任务 %d 被中断: %v", job.ID, err)
+		if err := db.Model(&job).Update("status", models.OutboxStatusPending).Error; err != nil {
+			log.Printf("更新发送任务 %d 失败: %v", job.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		o.fail(account, &job, err)
+		return
+	}
+	job.Attempts++
+	o.complete(account, &job)
+}
+
+// throttle 检查发送限额，超出限额时将任务推迟到有余量时发送并返回 true；推迟不计入尝试次数
+func (o *Outbox) throttle(account *models.Account, job *models.OutboxJob) bool {
+	wait, reason := o.limiter.Reserve(account, job.TargetEmail)
+	if wait <= 0 {
+		return false
+	}
+
+	log.Printf("发送任务 %d 超出发送限额（%s），%v 后发送", job.ID, reason, wait.Round(time.Second))
+	db := database.GetDB()
+	err := db.Model(job).Updates(map[string]interface{}{
+		"status":          models.OutboxStatusPending,
+		"next_attempt_at": time.Now().Add(wait),
+	}).Error
+	if err != nil {
+		log.Printf("更新发送任务 %d 失败: %v", job.ID, err)
+	}
+	return true
+}
+
+// Quotas 返回各账户和收件人的发送限额使用情况
+func (o *Outbox) Quotas() (*SendQuotas, error) {
+	return o.limiter.Quotas()
+}
+
+// outboxAccount 查找任务的发送账户，ID 为 0 时为环境变量配置的默认账户
+func outboxAccount(id uint) (*models.Account, error) {
+	if id == 0 {
+		if account := DefaultAccount(); account != nil {
+			return account, nil
+		}
+		return nil, errors.New("默认账户未配置（GMAIL_USER 为空）")
+	}
+
+	db := database.GetDB()
+	var account models.Account
+	if err := db.First(&account, id).Error; err != nil {
+		return nil, fmt.Errorf("邮箱账户 %d 不存在: %w", id, err)
+	}
+	return &account, nil
+}
+
+// smtpSenders 发送协程持有的各账户 SMTP 客户端，同一账户连续发送的邮件复用已认证的会话
+type smtpSenders struct {
+	clients map[uint]*accountSender
+}
+
+// accountSender 账户的 SMTP 客户端，账户配置更新后重新创建
+type accountSender struct {
+	client    *gmail.SMTPClient
+	updatedAt time.Time
+}
+
+// newSMTPSenders 创建空的 SMTP 客户端集合
+func newSMTPSenders() *smtpSenders {
+	return &smtpSenders{clients: make(map[uint]*accountSender)}
+}
+
+// send 使用账户的 SMTP 会话发送任务中的转发邮件
+func (s *smtpSenders) send(ctx context.Context, account *models.Account, job *models.OutboxJob) error {
+	sender, ok := s.clients[account.ID]
+	if !ok || !sender.updatedAt.Equal(account.UpdatedAt) {
+		if ok {
+			sender.client.Close()
+		}
+		_, smtpClient, err := NewAccountClients(account)
+		if err != nil {
+			delete(s.clients, account.ID)
+			return err
+		}
+		sender = &accountSender{client: smtpClient, updatedAt: account.UpdatedAt}
+		s.clients[account.ID] = sender
+	}
+	return sender.client.SendMessage(ctx, job.TargetEmail, job.Message)
+}
+
+// closeIdle 关闭空闲的 SMTP 会话
+func (s *smtpSenders) closeIdle() {
+	for _, sender := range s.clients {
+		sender.client.CloseIdle(gmail.SMTPSessionIdleTimeout)
+	}
+}
+
+// closeAll 关闭所有 SMTP 会话
+func (s *smtpSenders) closeAll() {
+	for id, sender := range s.clients {
+		sender.client.Close()
+		delete(s.clients, id)
+	}
+}
+
+// complete 记录发送成功，更新转发日志，原邮件的任务都已完成时执行转发后动作
+func (o *Outbox) complete(account *models.Account, job *models.OutboxJob) {
+	log.Printf("发送任务 %d 成功转发给: %s", job.ID, job.TargetEmail)
+	o.resumeAccount(account)
+
+	now := time.Now()
+	db := database.GetDB()
+	job.ErrorKind = ""
+	err := db.Model(job).Updates(map[string]interface{}{
+		"status":     models.OutboxStatusSent,
+		"attempts":   job.Attempts,
+		"last_error": "",
+		"error_kind": "",
+		"sent_at":    &now,
+	}).Error
+	if err != nil {
+		log.Printf("更新发送任务 %d 失败: %v", job.ID, err)
+	}
+
+	if err := markForwarded(job.MessageKey, job.TargetEmail); err != nil {
+		log.Printf("记录已转发邮件失败 [%s]: %v", job.MessageKey, err)
+	}
+	updateJobLog(job, models.ForwardStatusForwarded, "")
+	settleJobActions(o.ctx, account, job)
+}
+
+// fail 记录发送失败：账户级错误暂停账户的发送；永久错误或超过最大尝试次数时进入死信
+// （原邮件的任务都已完成时执行转发后动作），否则按退避时间重新排队
+func (o *Outbox) fail(account *models.Account, job *models.OutboxJob, sendErr error) {
+	kind, permanent := classifyJobError(sendErr)
+	job.ErrorKind = kind
+	if accountLevelError(kind) && account != nil {
+		o.pauseAccount(account, job, sendErr)
+		return
+	}
+	job.Attempts++
+	updates := map[string]interface{}{
+		"attempts":   job.Attempts,
+		"last_error": sendErr.Error(),
+		"error_kind": kind,
+	}
+
+	dead := permanent || job.Attempts >= o.maxAttempts
+	if permanent {
+		log.Printf("发送任务 %d 遇到永久错误 (%s)，不再重试: %v", job.ID, kind, sendErr)
+		updates["status"] = models.OutboxStatusDead
+	} else if dead {
+		log.Printf("发送任务 %d 失败 %d 次，进入死信: %v", job.ID, job.Attempts, sendErr)
+		updates["status"] = models.OutboxStatusDead
+	} else {
+		delay := o.backoff(job.Attempts)
+		log.Printf("发送任务 %d 第 %d 次发送失败，%v 后重试: %v", job.ID, job.Attempts, delay.Round(time.Second), sendErr)
+		updates["status"] = models.OutboxStatusPending
+		updates["next_attempt_at"] = time.Now().Add(delay)
+	}
+
+	db := database.GetDB()
+	if err := db.Model(job).Updates(updates).Error; err != nil {
+		log.Printf("更新发送任务 %d 失败: %v", job.ID, err)
+	}
+
+	if !dead {
+		updateJobLog(job, models.ForwardStatusQueued, sendErr.Error())
+		return
+	}
+	updateJobLog(job, models.ForwardStatusFailed, sendErr.Error())
+	settleJobActions(o.ctx, account, job)
+}
+
+// accountLevelError 判断是否为账户级错误：认证失败、TLS 错误和发件人被拒绝与任务本身无关，
+// 是账户配置或服务器的问题，该账户的所有任务都会同样失败
+func accountLevelError(kind string) bool {
+	switch kind {
+	case gmail.SendErrorAuthFailed, gmail.SendErrorTLS, gmail.SendErrorSenderRejected:
+		return true
+	}
+	return false
+}
+
+// pauseAccount 遇到账户级错误时按账户连续失败次数退避，将该任务和账户的其他待发送任务一起推迟；
+// 不计入任务的尝试次数，也不进入死信，修复账户配置后可以通过重试接口立即发送
+func (o *Outbox) pauseAccount(account *models.Account, job *models.OutboxJob, sendErr error) {
+	o.pauseMu.Lock()
+	o.accountPauses[account.ID]++
+	failures := o.accountPauses[account.ID]
+	o.pauseMu.Unlock()
+
+	delay := o.backoff(failures)
+	resumeAt := time.Now().Add(delay)
+	log.Printf("账户 %s 遇到账户级错误 (%s)，暂停发送 %v: %v", account.Email, job.ErrorKind, delay.Round(time.Second), sendErr)
+
+	db := database.GetDB()
+	err := db.Model(job).Updates(map[string]interface{}{
+		"status":          models.OutboxStatusPending,
+		"last_error":      sendErr.Error(),
+		"error_kind":      job.ErrorKind,
+		"next_attempt_at": resumeAt,
+	}).Error
+	if err != nil {
+		log.Printf("更新发送任务 %d 失败: %v", job.ID, err)
+	}
+	err = db.Model(&models.OutboxJob{}).
+		Where("account_id = ? AND status = ? AND next_attempt_at < ?", job.AccountID, models.OutboxStatusPending, resumeAt).
+		Update("next_attempt_at", resumeAt).Error
+	if err != nil {
+		log.Printf("推迟账户 %s 的发送任务失败: %v", account.Email, err)
+	}
+
+	updateJobLog(job, models.ForwardStatusQueued, sendErr.Error())
+}
+
+// resumeAccount 账户发送成功后清零连续失败次数
+func (o *Outbox) resumeAccount(account *models.Account) {
+	o.pauseMu.Lock()
+	delete(o.accountPauses, account.ID)
+	o.pauseMu.Unlock()
+}
+
+// classifyJobError 返回发送错误的原因分类以及是否为永久错误；非 SMTP 错误（如账户配置错误）按临时错误处理
+func classifyJobError(err error) (string, bool) {
+	var sendErr *gmail.SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Kind, sendErr.Permanent
+	}
+	return gmail.SendErrorUnknown, false
+}
+
+// backoff 计算第 attempts 次失败后的重试间隔：retryBase·2^(attempts-1)，不超过 retryMax，
+// 并在后一半区间内随机抖动，避免大量任务同时重试
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.retryBase
+	for i := 1; i < attempts && d < o.retryMax; i++ {
+		d *= 2
+	}
+	if d > o.retryMax {
+		d = o.retryMax
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// updateJobLog 更新任务对应的转发日志，同步尝试次数和失败原因分类
+func updateJobLog(job *models.OutboxJob, status, errMsg string) {
+	if job.ForwardLogID == 0 {
+		return
+	}
+
+	db := database.GetDB()
+	err := db.Model(&models.ForwardLog{}).Where("id = ?", job.ForwardLogID).Updates(map[string]interface{}{
+		"status":     status,
+		"attempts":   job.Attempts,
+		"error":      errMsg,
+		"error_kind": job.ErrorKind,
+	}).Error
+	if err != nil {
+		log.Printf("更新转发日志 %d 失败: %v", job.ForwardLogID, err)
+	}
+}
+
+// settleJobActions 任务完成后检查原邮件的所有发送任务是否都已完成，是则对原邮件执行一次转发后动作；
+// account 为空时（如发送账户已删除）重新查找
+func settleJobActions(ctx context.Context, account *models.Account, job *models.OutboxJob) {
+	if job.ActionGroupID == nil {
+		return
+	}
+	group, actions, err := claimActionGroup(*job.ActionGroupID)
+	if err != nil {
+		log.Printf("检查转发后动作失败 [%s]: %v", job.Subject, err)
+		return
+	}
+	if group == nil || len(actions) == 0 {
+		return
+	}
+
+	if account == nil {
+		if account, err = outboxAccount(group.AccountID); err != nil {
+			log.Printf("执行转发后动作失败 [%s]: %v", group.Subject, err)
+			return
+		}
+	}
+	applyGroupActions(ctx, account, group, actions)
+}
+
+// applyGroupActions 连接 IMAP 服务器对动作组的原邮件执行转发后动作，失败只记录日志
+func applyGroupActions(ctx context.Context, account *models.Account, group *models.MailActionGroup, actions []models.MailAction) {
+	if len(actions) == 0 || group.UID == 0 || group.Mailbox == "" {
+		return
+	}
+
+	imapClient, _, err := NewAccountClients(account)
+	if err != nil {
+		log.Printf("执行转发后动作失败 [%s]: %v", group.Subject, err)
+		return
+	}
+	if err := imapClient.Connect(ctx); err != nil {
+		log.Printf("执行转发后动作失败 [%s]: %v", group.Subject, err)
+		return
+	}
+	defer imapClient.Disconnect()
+
+	if err := imapClient.SelectMailbox(group.Mailbox); err != nil {
+		log.Printf("执行转发后动作失败 [%s]: %v", group.Subject, err)
+		return
+	}
+
+	ep := NewEmailProcessor(imapClient, nil)
+	ep.account = account
+	ep.applyMailActions(&gmail.Email{UID: group.UID, Subject: group.Subject, Mailbox: group.Mailbox}, actions)
+}
+
+// enqueueForward 在同一事务中保存转发日志和发送任务，group 不为空且尚未保存时一起创建动作组
+func (ep *EmailProcessor) enqueueForward(entry *models.ForwardLog, job *models.OutboxJob, group *models.MailActionGroup) error {
+	if id := ep.accountID(); id != 0 {
+		entry.AccountID = &id
+	}
+	job.Status = models.OutboxStatusPending
+	job.NextAttemptAt = time.Now()
+
+	db := database.GetDB()
+	newGroup := group != nil && group.ID == 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if newGroup {
+			if err := tx.Create(group).Error; err != nil {
+				return fmt.Errorf("保存转发后动作失败: %w", err)
+			}
+		}
+		if group != nil {
+			job.ActionGroupID = &group.ID
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("保存转发日志失败: %w", err)
+		}
+		job.ForwardLogID = entry.ID
+		if err := tx.Create(job).Error; err != nil {
+			return fmt.Errorf("保存发送任务失败: %w", err)
+		}
+		entry.OutboxJobID = &job.ID
+		if err := tx.Model(entry).Update("outbox_job_id", job.ID).Error; err != nil {
+			return fmt.Errorf("保存转发日志失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil && newGroup {
+		// 事务已回滚，下一个目标写入发送队列时重新创建动作组
+		group.ID = 0
+	}
+	return err
+}
+
+// RetryOutboxJob 将死信或等待重试的任务重新放回队列立即发送，尝试次数清零；
+// 原邮件的转发后动作已经执行过时不会再次执行
+func RetryOutboxJob(job *models.OutboxJob) error {
+	if job.Status == models.OutboxStatusSent || job.Status == models.OutboxStatusSending {
+		return fmt.Errorf("任务状态为 %s，不能重试", job.Status)
+	}
+
+	job.Status = models.OutboxStatusPending
+	job.Attempts = 0
+	job.ErrorKind = ""
+	job.NextAttemptAt = time.Now()
+	job.DedupKey = outboxDedupKey(job.MessageKey, job.TargetEmail)
+	err := resetOutboxJob(job, models.ForwardStatusQueued, "")
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return errors.New("该邮件已有发送给同一目标的其他任务")
+	}
+	return err
+}
+
+// DiscardOutboxJob 丢弃未发送的任务，之后回溯处理可以重新转发该邮件
+func DiscardOutboxJob(job *models.OutboxJob) error {
+	if job.Status == models.OutboxStatusSent || job.Status == models.OutboxStatusSending {
+		return fmt.Errorf("任务状态为 %s，不能丢弃", job.Status)
+	}
+
+	job.Status = models.OutboxStatusDiscarded
+	job.DedupKey = nil
+	if err := resetOutboxJob(job, models.ForwardStatusFailed, "发送任务已丢弃"); err != nil {
+		return err
+	}
+
+	// 丢弃的任务不计入转发结果，其余任务都已完成时执行原邮件的转发后动作
+	go settleJobActions(context.Background(), nil, job)
+	return nil
+}
+
+// resetOutboxJob 保存手动修改的任务状态并同步转发日志；
+// 只更新仍未被发送协程认领的任务，避免与正在进行的发送冲突
+func resetOutboxJob(job *models.OutboxJob, logStatus, logError string) error {
+	db := database.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OutboxJob{}).
+			Where("id = ? AND status NOT IN ?", job.ID, []string{models.OutboxStatusSent, models.OutboxStatusSending}).
+			Updates(map[string]interface{}{
+				"status":          job.Status,
+				"attempts":        job.Attempts,
+				"error_kind":      job.ErrorKind,
+				"next_attempt_at": job.NextAttemptAt,
+				"dedup_key":       job.DedupKey,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新发送任务失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("任务正在发送或已发送")
+		}
+
+		if job.ForwardLogID == 0 {
+			return nil
+		}
+		err := tx.Model(&models.ForwardLog{}).Where("id = ?", job.ForwardLogID).Updates(map[string]interface{}{
+			"status":     logStatus,
+			"error":      logError,
+			"error_kind": job.ErrorKind,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("更新转发日志失败: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	o := &Outbox{retryBase: 30 * time.Second, retryMax: time.Hour}
+
+	tests := []struct {
+		attempts int
+		max      time.Duration // 抖动前的退避时间，结果在 [max/2, max] 内
+	}{
+		{attempts: 0, max: 30 * time.Second},
+		{attempts: 1, max: 30 * time.Second},
+		{attempts: 2, max: time.Minute},
+		{attempts: 3, max: 2 * time.Minute},
+		{attempts: 7, max: 32 * time.Minute},
+		{attempts: 8, max: time.Hour}, // 64 分钟超过上限
+		{attempts: 100, max: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempts=%d", tt.attempts), func(t *testing.T) {
+			seen := make(map[time.Duration]bool)
+			for i := 0; i < 200; i++ {
+				d := o.backoff(tt.attempts)
+				if d < tt.max/2 || d > tt.max {
+					t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempts, d, tt.max/2, tt.max)
+				}
+				seen[d] = true
+			}
+			if len(seen) < 2 {
+				t.Errorf("backoff(%d) returned the same delay 200 times, want jitter", tt.attempts)
+			}
+		})
+	}
+}
+
+func TestOutboxBackoffSmallBase(t *testing.T) {
+	// 退避时间为 1ns 时抖动区间为 [0, 1ns]，不能因为 rand.Int63n(0) 崩溃
+	o := &Outbox{retryBase: time.Nanosecond, retryMax: time.Nanosecond}
+	for i := 0; i < 10; i++ {
+		if d := o.backoff(1); d < 0 || d > time.Nanosecond {
+			t.Fatalf("backoff(1) = %v, want within [0, 1ns]", d)
+		}
+	}
+}
+
+func TestAccountLevelError(t *testing.T) {
+	tests := []struct {
+		kind string
+		want bool
+	}{
+		{gmail.SendErrorAuthFailed, true},
+		{gmail.SendErrorTLS, true},
+		{gmail.SendErrorSenderRejected, true},
+		{gmail.SendErrorRecipientRejected, false},
+		{gmail.SendErrorMessageRejected, false},
+		{gmail.SendErrorServerUnavailable, false},
+		{gmail.SendErrorTimeout, false},
+		{gmail.SendErrorNetwork, false},
+		{gmail.SendErrorUnknown, false},
+	}
+
+	for _, tt := range tests {
+		if got := accountLevelError(tt.kind); got != tt.want {
+			t.Errorf("accountLevelError(%q) = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+}
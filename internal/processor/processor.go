@@ -1,24 +1,34 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"gmail-forwarding/internal/database"
 	"gmail-forwarding/internal/gmail"
 	"gmail-forwarding/internal/models"
+
+	"gorm.io/gorm"
 )
 
 // EmailProcessor 邮件处理器
 type EmailProcessor struct {
 	imapClient *gmail.IMAPClient
 	smtpClient *gmail.SMTPClient
+	account    *models.Account // 处理的邮箱账户，为空时只使用未限定账户的规则
+	backfill   bool            // 正在回溯处理，写入发送队列的任务不执行转发后动作
 	mu         sync.Mutex
 }
 
+// errNoIMAPClient 试运行处理器没有 IMAP 客户端，不能执行需要连接服务器的操作
+var errNoIMAPClient = errors.New("处理器没有 IMAP 客户端（试运行处理器不能连接服务器）")
+
 // NewEmailProcessor 创建新的邮件处理器
 func NewEmailProcessor(imapClient *gmail.IMAPClient, smtpClient *gmail.SMTPClient) *EmailProcessor {
 	return &EmailProcessor{
@@ -29,14 +39,15 @@ func NewEmailProcessor(imapClient *gmail.IMAPClient, smtpClient *gmail.SMTPClien
 
 // SubjectParseResult 主题解析结果
 type SubjectParseResult struct {
-	Keyword string
-	Email   string
+	Keyword string `json:"keyword"`
+	Email   string `json:"email"`
 }
 
 // parseSubject 解析邮件主题，提取关键字和邮箱地址
 func (ep *EmailProcessor) parseSubject(subject string) (*SubjectParseResult, error) {
 	// 使用正则表达式解析主题格式：关键字 - 邮箱地址
-	re := regexp.MustCompile(`^(.+?)\s*-\s*(.+?)$`)
+	// 邮箱地址不含空白，因此关键字中的连字符（如 "订单通知-加急 - a@b.com"）不会被当作分隔符
+	re := regexp.MustCompile(`^(.+?)\s*-\s*(\S+@\S+)$`)
 	matches := re.FindStringSubmatch(strings.TrimSpace(subject))
 
 	if len(matches) != 3 {
@@ -58,42 +69,112 @@ func (ep *EmailProcessor) parseSubject(subject string) (*SubjectParseResult, err
 	}, nil
 }
 
-// loadActiveRules 预加载所有启用的转发规则
-func (ep *EmailProcessor) loadActiveRules() (map[string]bool, error) {
+// ruleSet 单次处理预加载的转发规则和策略，避免每封邮件查询数据库
+type ruleSet struct {
+	rules        *keywordMatcher
+	destinations *destinationPolicy
+	senders      *senderPolicy
+}
+
+// loadRuleSet 预加载转发规则和策略，includeInactive 为 true 时同时加载未启用的规则
+func (ep *EmailProcessor) loadRuleSet(includeInactive bool) (*ruleSet, error) {
+	rules, err := ep.loadRules(includeInactive)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations, err := ep.loadDestinationPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	senders, err := ep.loadSenderPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ruleSet{
+		rules:        rules,
+		destinations: destinations,
+		senders:      senders,
+	}, nil
+}
+
+// loadRules 预加载转发规则，按优先级排序并编译为规则匹配器
+func (ep *EmailProcessor) loadRules(includeInactive bool) (*keywordMatcher, error) {
 	db := database.GetDB()
 	var rules []models.ForwardingRule
 
-	err := db.Where("active = ?", true).Find(&rules).Error
-	if err != nil {
+	query := db.Preload("Recipients").Preload("Accounts").Order("priority, id")
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+	if err := query.Find(&rules).Error; err != nil {
 		return nil, fmt.Errorf("加载转发规则失败: %w", err)
 	}
 
-	rulesMap := make(map[string]bool)
+	matcher, errs := newKeywordMatcher(ep.scopeRules(rules))
+	for _, err := range errs {
+		log.Printf("跳过无效的转发规则: %v", err)
+	}
+
+	log.Printf("已加载 %d 个转发规则", matcher.size())
+	return matcher, nil
+}
+
+// scopeRules 过滤出适用于当前账户的规则：未限定账户的规则适用于所有账户
+func (ep *EmailProcessor) scopeRules(rules []models.ForwardingRule) []models.ForwardingRule {
+	scoped := rules[:0]
 	for _, rule := range rules {
-		rulesMap[rule.Keyword] = true
+		if len(rule.Accounts) == 0 {
+			scoped = append(scoped, rule)
+			continue
+		}
+		for _, account := range rule.Accounts {
+			if ep.account != nil && ep.account.ID != 0 && account.ID == ep.account.ID {
+				scoped = append(scoped, rule)
+				break
+			}
+		}
 	}
+	return scoped
+}
 
-	log.Printf("已加载 %d 个启用的转发规则", len(rulesMap))
-	return rulesMap, nil
+// matchResult 单封邮件的规则匹配结果
+type matchResult struct {
+	parse *SubjectParseResult
+	rules []*models.ForwardingRule // 按优先级排列的命中规则
+	trace []models.RuleTrace
 }
 
-// shouldForward 检查邮件是否应该转发
-func (ep *EmailProcessor) shouldForward(email *gmail.Email, activeRules map[string]bool) (*SubjectParseResult, bool) {
+// shouldForward 检查邮件是否应该转发，返回解析结果、命中的规则、匹配记录以及不转发的原因
+//
+// 主题不是"关键字 - 邮箱地址"格式时，以整个主题作为关键字匹配，
+// 此时解析结果中的 Email 为空，由规则绑定的固定收件人接收转发。
+func (ep *EmailProcessor) shouldForward(email *gmail.Email, matcher *keywordMatcher) (*matchResult, error) {
 	// 解析邮件主题
-	parseResult, err := ep.parseSubject(email.Subject)
-	if err != nil {
-		log.Printf("邮件主题解析失败: %v", err)
-		return nil, false // 不是转发格式的邮件，跳过
+	parseResult, parseErr := ep.parseSubject(email.Subject)
+	if parseErr != nil {
+		parseResult = &SubjectParseResult{Keyword: strings.TrimSpace(email.Subject)}
 	}
 
-	// 内存中快速匹配关键字
-	if !activeRules[parseResult.Keyword] {
+	// 内存中按优先级匹配规则
+	rules, trace := matcher.matchAll(parseResult.Keyword, email)
+	result := &matchResult{parse: parseResult, rules: rules, trace: trace}
+	if len(rules) == 0 {
+		if parseErr != nil {
+			log.Printf("邮件主题解析失败: %v", parseErr)
+			return result, parseErr // 不是转发格式的邮件，跳过
+		}
 		log.Printf("关键字 '%s' 没有对应的转发规则", parseResult.Keyword)
-		return parseResult, false
+		return result, fmt.Errorf("关键字 '%s' 没有对应的转发规则", parseResult.Keyword)
 	}
 
-	log.Printf("匹配到转发规则 - 关键字: %s, 转发邮箱: %s", parseResult.Keyword, parseResult.Email)
-	return parseResult, true
+	for _, rule := range rules {
+		log.Printf("匹配到转发规则 - 规则: %s (%s, 优先级 %d), 关键字: %s, 转发邮箱: %s",
+			rule.Keyword, matchType(rule), rule.Priority, parseResult.Keyword, parseResult.Email)
+	}
+	return result, nil
 }
 
 // findOrCreateRecipient 根据邮箱地址查找或创建转发对象
@@ -129,92 +210,339 @@ func (ep *EmailProcessor) findOrCreateRecipient(email string) (*models.Recipient
 	return &recipient, nil
 }
 
+// ProcessEmails 处理邮件主函数，依次处理账户配置的每个邮箱文件夹
+//
+// ctx 取消时关闭 IMAP 连接并停止处理，未处理完的文件夹不推进同步位置，下次处理时重新获取。
+func (ep *EmailProcessor) ProcessEmails(ctx context.Context) error {
+	if ep.imapClient == nil {
+		return errNoIMAPClient
+	}
 
-// ProcessEmails 处理邮件主函数
-func (ep *EmailProcessor) ProcessEmails() error {
 	ep.mu.Lock()
 	defer ep.mu.Unlock()
 
-	log.Println("开始处理邮件...")
+	log.Printf("开始处理邮件 [%s]...", ep.imapClient.Username())
 
-	// 预加载所有启用的转发规则
-	activeRules, err := ep.loadActiveRules()
+	// 预加载所有启用的转发规则和策略
+	rs, err := ep.loadRuleSet(false)
 	if err != nil {
-		return fmt.Errorf("加载转发规则失败: %w", err)
+		return err
 	}
 
 	// 连接 IMAP 服务器
-	if err := ep.imapClient.Connect(); err != nil {
+	if err := ep.imapClient.Connect(ctx); err != nil {
 		return fmt.Errorf("连接IMAP服务器失败: %w", err)
 	}
 	defer ep.imapClient.Disconnect()
 
-	// 获取未读邮件
-	emails, err := ep.imapClient.FetchUnreadEmails()
+	var firstErr error
+	for _, mailbox := range AccountMailboxes(ep.account) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("邮件处理已取消: %w", err)
+		}
+		if err := ep.processMailbox(ctx, mailbox, rs); err != nil {
+			log.Printf("处理邮箱 %s 失败: %v", mailbox, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	log.Println("邮件处理完成")
+	return firstErr
+}
+
+// processMailbox 按 UID 增量获取并处理单个邮箱文件夹的新邮件
+func (ep *EmailProcessor) processMailbox(ctx context.Context, mailbox string, rs *ruleSet) error {
+	syncState, err := ep.loadSyncState(mailbox)
+	if err != nil {
+		return err
+	}
+
+	emails, nextState, err := ep.imapClient.FetchNewEmails(mailbox, syncState)
 	if err != nil {
 		return fmt.Errorf("获取邮件失败: %w", err)
 	}
 
 	if len(emails) == 0 {
-		log.Println("没有未读邮件")
+		log.Printf("%s 没有新邮件", mailbox)
+		if err := ep.saveSyncState(mailbox, nextState); err != nil {
+			log.Printf("保存邮箱同步状态失败: %v", err)
+		}
 		return nil
 	}
 
-	log.Printf("找到 %d 封未读邮件", len(emails))
+	log.Printf("%s 找到 %d 封新邮件", mailbox, len(emails))
 
 	// 处理每封邮件
-	for _, email := range emails {
-		if err := ep.processEmailWithRules(email, activeRules); err != nil {
+	uids := make([]uint32, 0, len(emails))
+	actions := make([]*messageActions, len(emails))
+	for i, email := range emails {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("邮件处理已取消: %w", err)
+		}
+		var err error
+		if actions[i], err = ep.processEmailWithRules(email, rs); err != nil {
 			log.Printf("处理邮件失败 [%s]: %v", email.Subject, err)
 		}
+		uids = append(uids, email.UID)
+	}
 
-		// 标记邮件为已读
-		if err := ep.imapClient.MarkAsRead(email.MessageID); err != nil {
-			log.Printf("标记邮件已读失败 [%s]: %v", email.MessageID, err)
-		}
+	// 一次性将处理过的邮件标记为已读
+	if err := ep.imapClient.MarkAsRead(uids); err != nil {
+		log.Printf("标记邮件已读失败: %v", err)
 	}
 
-	log.Println("邮件处理完成")
+	// 执行规则配置的转发后动作（移动、归档等会使邮件离开当前文件夹，需在标记已读之后执行）；
+	// 有目标写入发送队列的邮件由发送队列在所有任务完成后执行
+	for i, email := range emails {
+		ep.finishMailActions(email, actions[i])
+	}
+
+	// 所有邮件处理完成后再推进同步位置，中途崩溃时下次会重新获取（由去重保证不重复转发）
+	if err := ep.saveSyncState(mailbox, nextState); err != nil {
+		log.Printf("保存邮箱同步状态失败: %v", err)
+	}
 	return nil
 }
 
 // processEmail 处理单封邮件（旧方法，保留兼容性）
 func (ep *EmailProcessor) processEmail(email *gmail.Email) error {
 	// 加载规则并调用新方法
-	activeRules, err := ep.loadActiveRules()
+	rs, err := ep.loadRuleSet(false)
 	if err != nil {
-		return fmt.Errorf("加载转发规则失败: %w", err)
+		return err
 	}
-	return ep.processEmailWithRules(email, activeRules)
+	_, err = ep.processEmailWithRules(email, rs)
+	return err
 }
 
-// processEmailWithRules 使用预加载规则处理单封邮件
-func (ep *EmailProcessor) processEmailWithRules(email *gmail.Email, activeRules map[string]bool) error {
+// processEmailWithRules 使用预加载规则处理单封邮件，并为每个转发目标记录转发日志
+//
+// 邮件会转发给所有命中规则的目标，同一目标只转发一次（使用第一条命中规则的转发方式）。
+// 返回各规则的转发后动作及处理时已确定的结果；有目标写入发送队列时同时创建动作组，
+// 由发送队列在该邮件的所有任务完成后按最终结果执行一次。
+func (ep *EmailProcessor) processEmailWithRules(email *gmail.Email, rs *ruleSet) (*messageActions, error) {
 	log.Printf("处理邮件: %s", email.Subject)
 
 	// 检查邮件是否应该转发
-	parseResult, shouldForward := ep.shouldForward(email, activeRules)
-	if !shouldForward {
-		return nil // 不需要转发，跳过
+	match, err := ep.shouldForward(email, rs.rules)
+	if err != nil {
+		entry := newForwardLog(email, match, nil)
+		entry.Status = models.ForwardStatusSkipped
+		entry.Error = err.Error()
+		ep.saveForwardLog(entry)
+		return nil, nil // 不需要转发，跳过
+	}
+
+	var firstErr error
+	actions := &messageActions{}
+	seen := make(map[string]bool)
+	for _, rule := range match.rules {
+		// 检查发件人是否有权触发该规则
+		if err := rs.senders.check(email, rule); err != nil {
+			log.Printf("发件人未授权: %v", err)
+			entry := newForwardLog(email, match, rule)
+			entry.Status = models.ForwardStatusUnauthorized
+			entry.Error = err.Error()
+			ep.saveForwardLog(entry)
+			continue
+		}
+
+		// 确定转发目标：主题中的邮箱地址优先，否则使用规则绑定的固定收件人
+		targets := forwardTargets(match.parse, rule)
+		if len(targets) == 0 {
+			log.Printf("邮件主题中没有邮箱地址，规则 '%s' 也未绑定固定收件人", rule.Keyword)
+			entry := newForwardLog(email, match, rule)
+			entry.Status = models.ForwardStatusSkipped
+			entry.Error = "邮件主题中没有邮箱地址，规则也未绑定固定收件人"
+			ep.saveForwardLog(entry)
+			continue
+		}
+
+		ruleActions := models.RuleActions{
+			RuleID:         rule.ID,
+			SuccessActions: rule.SuccessActions,
+			FailureActions: rule.FailureActions,
+		}
+		for _, target := range targets {
+			key := strings.ToLower(target)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			status, err := ep.forwardTo(email, match, rule, target, rs, actions)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			// queued 的结果由发送队列决定：本次写入的任务按动作组合并结果，
+			// 已在队列中的任务由写入它的那次处理的动作组执行动作，这里不计入
+			switch status {
+			case models.ForwardStatusForwarded, models.ForwardStatusDuplicate:
+				ruleActions.Succeeded = true
+			case models.ForwardStatusFailed:
+				ruleActions.Failed = true
+			}
+		}
+		actions.add(ruleActions)
+	}
+	return actions, firstErr
+}
+
+// forwardTargets 获取转发目标邮箱列表
+func forwardTargets(parseResult *SubjectParseResult, rule *models.ForwardingRule) []string {
+	if parseResult.Email != "" {
+		return []string{parseResult.Email}
+	}
+
+	targets := make([]string, 0, len(rule.Recipients))
+	for _, recipient := range rule.Recipients {
+		targets = append(targets, recipient.Email)
+	}
+	return targets
+}
+
+// forwardTo 检查单个转发目标并将转发邮件写入发送队列，记录转发日志，返回该目标的转发结果状态；
+// 只有已成功转发过时才返回 duplicate，目标已在发送队列中（等待发送或死信）时返回 queued
+func (ep *EmailProcessor) forwardTo(email *gmail.Email, match *matchResult, rule *models.ForwardingRule, target string, rs *ruleSet, actions *messageActions) (string, error) {
+	entry := newForwardLog(email, match, rule)
+	entry.TargetEmail = target
+	defer func() {
+		// 写入发送队列时日志已随任务一起保存
+		if entry.ID == 0 {
+			ep.saveForwardLog(entry)
+		}
+	}()
+
+	// 检查转发目标是否在允许范围内，防止被利用为开放中继
+	if err := rs.destinations.check(target); err != nil {
+		log.Printf("拒绝转发: %v", err)
+		entry.Status = models.ForwardStatusRejected
+		entry.Error = err.Error()
+		return entry.Status, nil
 	}
 
 	// 查找或创建转发对象
-	recipient, err := ep.findOrCreateRecipient(parseResult.Email)
+	recipient, err := ep.findOrCreateRecipient(target)
 	if err != nil {
 		log.Printf("查找或创建转发对象失败: %v", err)
-		return nil
+		entry.Status = models.ForwardStatusFailed
+		entry.Error = err.Error()
+		return entry.Status, nil
 	}
 
 	log.Printf("找到转发对象: %s <%s>", recipient.Name, recipient.Email)
 
-	// 转发邮件
-	err = ep.smtpClient.ForwardEmail(email, recipient.Email)
+	// 检查是否已经转发过，保证重复处理时不会重复发送
+	key := messageKey(email)
+	forwarded, err := ep.alreadyForwarded(key, recipient.Email)
 	if err != nil {
-		log.Printf("转发邮件失败: %v", err)
-		return err
+		log.Printf("检查重复转发失败: %v", err)
+		entry.Status = models.ForwardStatusFailed
+		entry.Error = err.Error()
+		return entry.Status, err
+	}
+	if forwarded {
+		log.Printf("邮件 %s 已转发给 %s，跳过重复转发", key, recipient.Email)
+		entry.Status = models.ForwardStatusDuplicate
+		entry.Error = "邮件已转发过，跳过重复转发"
+		return entry.Status, nil
+	}
+	queued, err := ep.alreadyQueued(key, recipient.Email)
+	if err != nil {
+		log.Printf("检查发送队列失败: %v", err)
+		entry.Status = models.ForwardStatusFailed
+		entry.Error = err.Error()
+		return entry.Status, err
+	}
+	if queued {
+		log.Printf("邮件 %s 已在发送队列中（%s），跳过重复转发", key, recipient.Email)
+		entry.Status = models.ForwardStatusDuplicate
+		entry.Error = "邮件已在发送队列中，跳过重复转发"
+		return models.ForwardStatusQueued, nil
+	}
+
+	// 渲染转发邮件并写入发送队列，由后台发送队列发送和重试
+	job := &models.OutboxJob{
+		AccountID:   ep.accountID(),
+		RuleID:      &rule.ID,
+		MessageKey:  key,
+		TargetEmail: recipient.Email,
+		DedupKey:    outboxDedupKey(key, recipient.Email),
+		Subject:     email.Subject,
+		Message:     ep.smtpClient.RenderForwardMessage(email, recipient.Email, forwardMode(rule)),
+		Mailbox:     email.Mailbox,
+		UID:         email.UID,
+	}
+	// 回溯处理不执行转发后动作
+	var group *models.MailActionGroup
+	if !ep.backfill {
+		group = actions.actionGroup(ep.accountID(), key, email)
+	}
+	entry.Status = models.ForwardStatusQueued
+	if err := ep.enqueueForward(entry, job, group); err != nil {
+		entry.ID = 0
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// 其他处理器（手动处理、回溯处理或重新加载前的定时任务）已同时写入了同一目标的任务
+			log.Printf("邮件 %s 已在发送队列中（%s），跳过重复转发", key, recipient.Email)
+			entry.Status = models.ForwardStatusDuplicate
+			entry.Error = "邮件已在发送队列中，跳过重复转发"
+			return models.ForwardStatusQueued, nil
+		}
+		log.Printf("写入发送队列失败: %v", err)
+		entry.Status = models.ForwardStatusFailed
+		entry.Error = err.Error()
+		return entry.Status, err
 	}
 
-	log.Printf("邮件成功转发给: %s", recipient.Email)
+	log.Printf("转发给 %s 的邮件已写入发送队列 (任务 %d)", recipient.Email, job.ID)
+	return entry.Status, nil
+}
 
-	return nil
-}
\ No newline at end of file
+// forwardMode 获取规则的转发方式，未设置时使用正文方式
+func forwardMode(rule *models.ForwardingRule) gmail.ForwardMode {
+	if rule.ForwardMode == models.ForwardModeAttachment {
+		return gmail.ForwardModeAttachment
+	}
+	return gmail.ForwardModeInline
+}
+
+// newForwardLog 根据邮件和匹配结果创建转发日志记录，rule 可以为空
+func newForwardLog(email *gmail.Email, match *matchResult, rule *models.ForwardingRule) *models.ForwardLog {
+	entry := &models.ForwardLog{
+		MessageID:   messageKey(email),
+		Subject:     email.Subject,
+		From:        email.From,
+		Mailbox:     email.Mailbox,
+		Labels:      email.Labels,
+		Keyword:     match.parse.Keyword,
+		Status:      models.ForwardStatusSkipped,
+		ProcessedAt: time.Now(),
+		MatchTrace:  match.trace,
+	}
+	if rule != nil {
+		entry.RuleID = &rule.ID
+	}
+	return entry
+}
+
+// saveForwardLog 保存转发日志，写入失败只记录错误不影响邮件处理
+func (ep *EmailProcessor) saveForwardLog(entry *models.ForwardLog) {
+	if id := ep.accountID(); id != 0 {
+		entry.AccountID = &id
+	}
+
+	db := database.GetDB()
+	if err := db.Create(entry).Error; err != nil {
+		log.Printf("保存转发日志失败 [%s]: %v", entry.MessageID, err)
+	}
+}
+
+// accountID 返回处理的账户ID，环境变量配置的默认账户为 0
+func (ep *EmailProcessor) accountID() uint {
+	if ep.account == nil {
+		return 0
+	}
+	return ep.account.ID
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		keyword string
+		email   string
+		wantErr bool
+	}{
+		{subject: "订单通知 - a@b.com", keyword: "订单通知", email: "a@b.com"},
+		{subject: "订单通知-a@b.com", keyword: "订单通知", email: "a@b.com"},
+		{subject: "  订单通知 - a@b.com  ", keyword: "订单通知", email: "a@b.com"},
+		{subject: "订单通知-加急 - a@b.com", keyword: "订单通知-加急", email: "a@b.com"},
+		{subject: "Re: build-failed - ops@example.com", keyword: "Re: build-failed", email: "ops@example.com"},
+		{subject: "a - b - c - x@y.org", keyword: "a - b - c", email: "x@y.org"},
+		{subject: "告警 - on-call@example.com", keyword: "告警", email: "on-call@example.com"},
+		{subject: "订单通知", wantErr: true},
+		{subject: "订单通知 - 加急", wantErr: true},
+		{subject: "订单通知 - not-an-email@", wantErr: true},
+		{subject: "- a@b.com", wantErr: true},
+	}
+
+	ep := &EmailProcessor{}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			got, err := ep.parseSubject(tt.subject)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSubject(%q) = %+v, want error", tt.subject, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSubject(%q) error: %v", tt.subject, err)
+			}
+			if got.Keyword != tt.keyword || got.Email != tt.email {
+				t.Errorf("parseSubject(%q) = (%q, %q), want (%q, %q)", tt.subject, got.Keyword, got.Email, tt.keyword, tt.email)
+			}
+		})
+	}
+}
+
+func TestProcessorWithoutIMAPClient(t *testing.T) {
+	// 试运行处理器没有 IMAP 客户端，需要连接服务器的操作返回错误而不是崩溃
+	ep := NewEmailProcessor(nil, nil)
+
+	if err := ep.ProcessEmails(context.Background()); !errors.Is(err, errNoIMAPClient) {
+		t.Errorf("ProcessEmails error = %v, want errNoIMAPClient", err)
+	}
+	opts := BackfillOptions{Since: time.Now().Add(-time.Hour)}
+	if _, err := ep.Backfill(context.Background(), opts, nil); !errors.Is(err, errNoIMAPClient) {
+		t.Errorf("Backfill error = %v, want errNoIMAPClient", err)
+	}
+	ep.applyMailActions(&gmail.Email{UID: 1}, []models.MailAction{{Type: models.MailActionArchive}})
+}
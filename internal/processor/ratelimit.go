@@ -0,0 +1,439 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gmail-forwarding/internal/config"
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/models"
+)
+
+// 发送限额的时间窗口
+const (
+	accountMinuteWindow = time.Minute
+	accountDayWindow    = 24 * time.Hour
+	recipientHourWindow = time.Hour
+
+	// recipientPruneInterval 清理已补满的收件人令牌桶的间隔
+	recipientPruneInterval = time.Minute
+)
+
+// quota 单项发送限额
+type quota interface {
+	wait(now time.Time) time.Duration // 获得发送余量还需等待的时间，有余量时为 0
+	take(now time.Time)               // 扣除一封邮件的余量
+	remaining(now time.Time) int      // 当前可以立即发送的数量
+}
+
+// tokenBucket 令牌桶：最多保存 limit 个令牌，每个 window 匀速补充 limit 个
+type tokenBucket struct {
+	limit  int
+	window time.Duration
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 创建令牌桶，used 为窗口内已经发送的数量（从发送记录恢复）
+func newTokenBucket(limit int, window time.Duration, used int64, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		window: window,
+		tokens: math.Max(0, float64(limit)-float64(used)),
+		last:   now,
+	}
+}
+
+// refill 按经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.limit), b.tokens+float64(b.limit)*elapsed.Seconds()/b.window.Seconds())
+	b.last = now
+}
+
+// wait 返回获得一个令牌还需等待的时间，有令牌时为 0
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.window) / float64(b.limit))
+}
+
+// take 扣除一个令牌
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// remaining 返回当前可以立即发送的数量
+func (b *tokenBucket) remaining(now time.Time) int {
+	b.refill(now)
+	return int(b.tokens)
+}
+
+// slidingWindow 滑动窗口计数：任意 window 时间内最多发送 limit 封，是严格的上限
+//
+// 记录窗口内每次发送的时间，只保留最近的 limit 个，最早的一个移出窗口后才有余量。
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+	sent   []time.Time // 按时间升序
+}
+
+// newSlidingWindow 创建滑动窗口，sent 为窗口内已经发送的时间（从发送记录恢复，按时间升序）
+func newSlidingWindow(limit int, window time.Duration, sent []time.Time) *slidingWindow {
+	if len(sent) > limit {
+		sent = sent[len(sent)-limit:]
+	}
+	return &slidingWindow{limit: limit, window: window, sent: sent}
+}
+
+// prune 移除已经移出窗口的发送记录
+func (w *slidingWindow) prune(now time.Time) {
+	start := now.Add(-w.window)
+	i := 0
+	for i < len(w.sent) && !w.sent[i].After(start) {
+		i++
+	}
+	w.sent = w.sent[i:]
+}
+
+// wait 返回窗口内最早的发送记录移出窗口还需等待的时间，有余量时为 0
+func (w *slidingWindow) wait(now time.Time) time.Duration {
+	w.prune(now)
+	if len(w.sent) < w.limit {
+		return 0
+	}
+	return w.sent[len(w.sent)-w.limit].Add(w.window).Sub(now)
+}
+
+// take 记录一次发送
+func (w *slidingWindow) take(now time.Time) {
+	w.sent = append(w.sent, now)
+	if len(w.sent) > w.limit {
+		w.sent = w.sent[len(w.sent)-w.limit:]
+	}
+}
+
+// remaining 返回当前可以立即发送的数量
+func (w *slidingWindow) remaining(now time.Time) int {
+	w.prune(now)
+	return w.limit - len(w.sent)
+}
+
+// RateLimiter 发送限额，使用令牌桶限制每个发送账户每分钟以及每个收件人每小时的发送数量，
+// 使用滑动窗口限制每个发送账户每天的发送数量
+//
+// 每日限额是严格的上限：任意 24 小时内按 outbox_jobs.sent_at 统计的发送数不超过限额，不会像令牌桶一样匀速补充。
+// 限额状态只保存在内存中，首次使用时从 outbox_jobs 中窗口内已发送的任务恢复，重启后不会重置每日限额。
+// 已扣除余量但发送失败的邮件仍计入限额，直到移出窗口或重启。
+type RateLimiter struct {
+	perMinute        int // 账户每分钟限额的全局默认值，0 表示不限制
+	perDay           int // 账户每天限额的全局默认值，0 表示不限制
+	perRecipientHour int // 每个收件人每小时限额，0 表示不限制
+
+	mu            sync.Mutex
+	accountMinute map[uint]*tokenBucket
+	accountDay    map[uint]*slidingWindow
+	recipientHour map[string]*tokenBucket
+	lastPrune     time.Time // 上次清理收件人令牌桶的时间
+}
+
+// NewRateLimiter 根据配置创建发送限额
+func NewRateLimiter() *RateLimiter {
+	cfg := config.GlobalConfig
+	return &RateLimiter{
+		perMinute:        parseLimit("SEND_LIMIT_PER_MINUTE", cfg.SendLimitPerMinute),
+		perDay:           parseLimit("SEND_LIMIT_PER_DAY", cfg.SendLimitPerDay),
+		perRecipientHour: parseLimit("SEND_LIMIT_PER_RECIPIENT_HOUR", cfg.SendLimitPerRecipientHour),
+		accountMinute:    make(map[uint]*tokenBucket),
+		accountDay:       make(map[uint]*slidingWindow),
+		recipientHour:    make(map[string]*tokenBucket),
+	}
+}
+
+// parseLimit 解析限额配置，0 表示不限制，无效时不限制
+func parseLimit(name, value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("无效的%s配置 %s，不限制发送数量", name, value)
+		return 0
+	}
+	return n
+}
+
+// accountLimits 返回账户每分钟和每天的限额，0 表示不限制；账户设置为 0 时使用全局配置，为负数时不限制
+func (l *RateLimiter) accountLimits(account *models.Account) (int, int) {
+	return accountLimit(account.SendLimitPerMinute, l.perMinute), accountLimit(account.SendLimitPerDay, l.perDay)
+}
+
+// accountLimit 合并账户和全局的单项限额
+func accountLimit(accountLimit, globalLimit int) int {
+	switch {
+	case accountLimit > 0:
+		return accountLimit
+	case accountLimit < 0:
+		return 0
+	}
+	return globalLimit
+}
+
+// Reserve 检查账户和收件人的限额，都有余量时各扣除一封的余量并返回 0；
+// 否则不扣除，返回最早可以发送的等待时间和超出的限额说明
+func (l *RateLimiter) Reserve(account *models.Account, recipient string) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) >= recipientPruneInterval {
+		l.pruneRecipients(now)
+	}
+	quotas := l.quotas(account, strings.ToLower(recipient), now)
+
+	var wait time.Duration
+	var reasons []string
+	for _, b := range quotas {
+		if d := b.quota.wait(now); d > 0 {
+			reasons = append(reasons, b.name)
+			if d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return wait, strings.Join(reasons, "、")
+	}
+
+	for _, b := range quotas {
+		b.quota.take(now)
+	}
+	return 0, ""
+}
+
+// namedQuota 带说明的限额
+type namedQuota struct {
+	name  string
+	quota quota
+}
+
+// quotas 返回发送一封邮件需要检查的限额，不限制的项不返回；调用方需持有锁
+func (l *RateLimiter) quotas(account *models.Account, recipient string, now time.Time) []namedQuota {
+	perMinute, perDay := l.accountLimits(account)
+
+	var quotas []namedQuota
+	if b := l.minuteBucket(account.ID, perMinute, now); b != nil {
+		quotas = append(quotas, namedQuota{fmt.Sprintf("账户每分钟 %d 封", perMinute), b})
+	}
+	if w := l.dayWindow(account.ID, perDay, now); w != nil {
+		quotas = append(quotas, namedQuota{fmt.Sprintf("账户每天 %d 封", perDay), w})
+	}
+	if b := l.recipientBucket(recipient, now); b != nil {
+		quotas = append(quotas, namedQuota{fmt.Sprintf("收件人每小时 %d 封", l.perRecipientHour), b})
+	}
+	return quotas
+}
+
+// minuteBucket 获取账户每分钟限额的令牌桶，不存在或限额变更时按发送记录重新创建；limit 为 0 时返回 nil
+func (l *RateLimiter) minuteBucket(accountID uint, limit int, now time.Time) quota {
+	if limit <= 0 {
+		delete(l.accountMinute, accountID)
+		return nil
+	}
+	if b, ok := l.accountMinute[accountID]; ok && b.limit == limit {
+		return b
+	}
+
+	b := newTokenBucket(limit, accountMinuteWindow, countSent("account_id = ?", accountID, now.Add(-accountMinuteWindow)), now)
+	l.accountMinute[accountID] = b
+	return b
+}
+
+// dayWindow 获取账户每日限额的滑动窗口，不存在或限额变更时按发送记录重新创建；limit 为 0 时返回 nil
+func (l *RateLimiter) dayWindow(accountID uint, limit int, now time.Time) quota {
+	if limit <= 0 {
+		delete(l.accountDay, accountID)
+		return nil
+	}
+	if w, ok := l.accountDay[accountID]; ok && w.limit == limit {
+		return w
+	}
+
+	w := newSlidingWindow(limit, accountDayWindow, sentTimes(accountID, now.Add(-accountDayWindow)))
+	l.accountDay[accountID] = w
+	return w
+}
+
+// recipientBucket 获取收件人的令牌桶；不限制时返回 nil
+func (l *RateLimiter) recipientBucket(recipient string, now time.Time) *tokenBucket {
+	if l.perRecipientHour <= 0 {
+		return nil
+	}
+	if b, ok := l.recipientHour[recipient]; ok {
+		return b
+	}
+
+	b := newTokenBucket(l.perRecipientHour, recipientHourWindow, countSent("LOWER(target_email) = ?", recipient, now.Add(-recipientHourWindow)), now)
+	l.recipientHour[recipient] = b
+	return b
+}
+
+// countSent 统计 since 之后发送成功的任务数，查询失败时按 0 处理
+func countSent(cond string, value interface{}, since time.Time) int64 {
+	db := database.GetDB()
+	var count int64
+	err := db.Model(&models.OutboxJob{}).
+		Where("status = ? AND sent_at >= ?", models.OutboxStatusSent, since).
+		Where(cond, value).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("统计已发送邮件数失败: %v", err)
+	}
+	return count
+}
+
+// sentTimes 返回账户 since 之后发送成功的时间（按时间升序），查询失败时返回空
+func sentTimes(accountID uint, since time.Time) []time.Time {
+	db := database.GetDB()
+	var times []time.Time
+	err := db.Model(&models.OutboxJob{}).
+		Where("status = ? AND account_id = ? AND sent_at >= ?", models.OutboxStatusSent, accountID, since).
+		Order("sent_at").
+		Pluck("sent_at", &times).Error
+	if err != nil {
+		log.Printf("查询已发送邮件失败: %v", err)
+	}
+	return times
+}
+
+// QuotaUsage 单项限额的使用情况
+type QuotaUsage struct {
+	Limit     int   `json:"limit"`               // 0 表示不限制
+	Sent      int64 `json:"sent"`                // 时间窗口内已发送的邮件数
+	Remaining *int  `json:"remaining,omitempty"` // 当前可以立即发送的数量，不限制时为空
+}
+
+// AccountQuota 发送账户的限额使用情况
+type AccountQuota struct {
+	AccountID uint       `json:"account_id"`
+	Email     string     `json:"email"`
+	PerMinute QuotaUsage `json:"per_minute"`
+	PerDay    QuotaUsage `json:"per_day"`
+}
+
+// RecipientQuota 收件人的限额使用情况
+type RecipientQuota struct {
+	Recipient string     `json:"recipient"`
+	PerHour   QuotaUsage `json:"per_hour"`
+}
+
+// SendQuotas 所有账户和最近有发送记录的收件人的限额使用情况
+type SendQuotas struct {
+	Accounts   []AccountQuota   `json:"accounts"`
+	Recipients []RecipientQuota `json:"recipients"`
+}
+
+// Quotas 返回当前启用账户和最近一小时内有发送记录的收件人的限额使用情况
+//
+// 先查询发送记录，持有锁时只读取内存中的限额状态，不阻塞发送。
+func (l *RateLimiter) Quotas() (*SendQuotas, error) {
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	quotas := &SendQuotas{Accounts: []AccountQuota{}, Recipients: []RecipientQuota{}}
+	for i := range accounts {
+		account := &accounts[i]
+		perMinute, perDay := l.accountLimits(account)
+		quotas.Accounts = append(quotas.Accounts, AccountQuota{
+			AccountID: account.ID,
+			Email:     account.Email,
+			PerMinute: QuotaUsage{Limit: perMinute, Sent: countSent("account_id = ?", account.ID, now.Add(-accountMinuteWindow))},
+			PerDay:    QuotaUsage{Limit: perDay, Sent: countSent("account_id = ?", account.ID, now.Add(-accountDayWindow))},
+		})
+	}
+
+	var recipients []string
+	db := database.GetDB()
+	err = db.Model(&models.OutboxJob{}).
+		Where("status = ? AND sent_at >= ?", models.OutboxStatusSent, now.Add(-recipientHourWindow)).
+		Distinct().
+		Pluck("LOWER(target_email)", &recipients).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询收件人发送记录失败: %w", err)
+	}
+	sort.Strings(recipients)
+
+	for _, recipient := range recipients {
+		quotas.Recipients = append(quotas.Recipients, RecipientQuota{
+			Recipient: recipient,
+			PerHour: QuotaUsage{
+				Limit: l.perRecipientHour,
+				Sent:  countSent("LOWER(target_email) = ?", recipient, now.Add(-recipientHourWindow)),
+			},
+		})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range quotas.Accounts {
+		q := &quotas.Accounts[i]
+		var minute, day quota
+		if b, ok := l.accountMinute[q.AccountID]; ok && b.limit == q.PerMinute.Limit {
+			minute = b
+		}
+		if w, ok := l.accountDay[q.AccountID]; ok && w.limit == q.PerDay.Limit {
+			day = w
+		}
+		q.PerMinute.fillRemaining(minute, now)
+		q.PerDay.fillRemaining(day, now)
+	}
+	for i := range quotas.Recipients {
+		q := &quotas.Recipients[i]
+		var hour quota
+		if b, ok := l.recipientHour[q.Recipient]; ok {
+			hour = b
+		}
+		q.PerHour.fillRemaining(hour, now)
+	}
+	l.pruneRecipients(now)
+	return quotas, nil
+}
+
+// fillRemaining 填充当前可以立即发送的数量：内存中已有限额状态时以它为准，否则按时间窗口内的发送数计算；
+// 不限制时不填充。调用方需持有锁
+func (u *QuotaUsage) fillRemaining(q quota, now time.Time) {
+	if u.Limit <= 0 {
+		return
+	}
+	remaining := u.Limit - int(u.Sent)
+	if q != nil {
+		remaining = q.remaining(now)
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	u.Remaining = &remaining
+}
+
+// pruneRecipients 删除已经补满的收件人令牌桶，避免长期运行后占用过多内存；调用方需持有锁
+func (l *RateLimiter) pruneRecipients(now time.Time) {
+	l.lastPrune = now
+	for recipient, b := range l.recipientHour {
+		if b.remaining(now) >= b.limit {
+			delete(l.recipientHour, recipient)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"gmail-forwarding/internal/models"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		limit     int
+		used      int64
+		takes     int           // 在 start 时扣除的令牌数
+		elapsed   time.Duration // 扣除后经过的时间
+		wait      time.Duration
+		remaining int
+	}{
+		{name: "full", limit: 10, wait: 0, remaining: 10},
+		{name: "restored from sent jobs", limit: 10, used: 4, remaining: 6},
+		{name: "used more than limit", limit: 10, used: 15, wait: 6 * time.Second, remaining: 0},
+		{name: "empty", limit: 10, takes: 10, wait: 6 * time.Second, remaining: 0},
+		{name: "partially refilled", limit: 10, takes: 10, elapsed: 3 * time.Second, wait: 3 * time.Second, remaining: 0},
+		{name: "one token refilled", limit: 10, takes: 10, elapsed: 6 * time.Second, wait: 0, remaining: 1},
+		{name: "refill capped at limit", limit: 10, takes: 3, elapsed: time.Hour, wait: 0, remaining: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.limit, time.Minute, tt.used, start)
+			for i := 0; i < tt.takes; i++ {
+				b.take(start)
+			}
+			now := start.Add(tt.elapsed)
+			if got := b.wait(now); got != tt.wait {
+				t.Errorf("wait = %v, want %v", got, tt.wait)
+			}
+			if got := b.remaining(now); got != tt.remaining {
+				t.Errorf("remaining = %d, want %d", got, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	tests := []struct {
+		name      string
+		limit     int
+		sent      []time.Time // 从发送记录恢复的发送时间
+		takes     []time.Time
+		now       time.Time
+		wait      time.Duration
+		remaining int
+	}{
+		{name: "empty", limit: 3, now: start, remaining: 3},
+		{
+			name:  "restored below limit",
+			limit: 3, sent: []time.Time{at(-2 * time.Hour)},
+			now: start, remaining: 2,
+		},
+		{
+			name:  "full until the oldest leaves the window",
+			limit: 3, sent: []time.Time{at(-20 * time.Hour), at(-10 * time.Hour)}, takes: []time.Time{start},
+			now: start, wait: 4 * time.Hour, remaining: 0,
+		},
+		{
+			name:  "oldest left the window",
+			limit: 3, sent: []time.Time{at(-20 * time.Hour), at(-10 * time.Hour)}, takes: []time.Time{start},
+			now: at(4 * time.Hour), remaining: 1,
+		},
+		{
+			name:  "send exactly at window boundary is pruned",
+			limit: 1, sent: []time.Time{at(-24 * time.Hour)},
+			now: start, remaining: 1,
+		},
+		{
+			name:  "no refill within the window",
+			limit: 2, takes: []time.Time{start, at(time.Minute)},
+			now: at(23 * time.Hour), wait: time.Hour, remaining: 0,
+		},
+		{
+			name:  "restored more than limit keeps the newest",
+			limit: 2, sent: []time.Time{at(-23 * time.Hour), at(-22 * time.Hour), at(-1 * time.Hour)},
+			now: start, wait: 2 * time.Hour, remaining: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newSlidingWindow(tt.limit, accountDayWindow, tt.sent)
+			for _, ts := range tt.takes {
+				w.take(ts)
+			}
+			if got := w.wait(tt.now); got != tt.wait {
+				t.Errorf("wait = %v, want %v", got, tt.wait)
+			}
+			if got := w.remaining(tt.now); got != tt.remaining {
+				t.Errorf("remaining = %d, want %d", got, tt.remaining)
+			}
+			if len(w.sent) > tt.limit {
+				t.Errorf("window keeps %d sends, want at most %d", len(w.sent), tt.limit)
+			}
+		})
+	}
+}
+
+func TestAccountLimit(t *testing.T) {
+	tests := []struct {
+		account, global, want int
+	}{
+		{account: 0, global: 20, want: 20},
+		{account: 0, global: 0, want: 0},
+		{account: 5, global: 20, want: 5},
+		{account: 50, global: 0, want: 50},
+		{account: -1, global: 20, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := accountLimit(tt.account, tt.global); got != tt.want {
+			t.Errorf("accountLimit(%d, %d) = %d, want %d", tt.account, tt.global, got, tt.want)
+		}
+	}
+}
+
+// newTestRateLimiter 创建已有限额状态的 RateLimiter，避免查询数据库
+func newTestRateLimiter(account *models.Account, perMinute, perDay, perRecipientHour int, recipients ...string) *RateLimiter {
+	now := time.Now()
+	l := &RateLimiter{
+		perMinute:        perMinute,
+		perDay:           perDay,
+		perRecipientHour: perRecipientHour,
+		accountMinute:    make(map[uint]*tokenBucket),
+		accountDay:       make(map[uint]*slidingWindow),
+		recipientHour:    make(map[string]*tokenBucket),
+		lastPrune:        now,
+	}
+	if perMinute > 0 {
+		l.accountMinute[account.ID] = newTokenBucket(perMinute, accountMinuteWindow, 0, now)
+	}
+	if perDay > 0 {
+		l.accountDay[account.ID] = newSlidingWindow(perDay, accountDayWindow, nil)
+	}
+	for _, recipient := range recipients {
+		l.recipientHour[recipient] = newTokenBucket(perRecipientHour, recipientHourWindow, 0, now)
+	}
+	return l
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	account := &models.Account{ID: 1, Email: "sender@example.com"}
+
+	tests := []struct {
+		name             string
+		perMinute        int
+		perDay           int
+		perRecipientHour int
+		sends            int // 连续预留的次数
+		allowed          int // 应立即允许的次数
+		reason           string
+	}{
+		{name: "per minute", perMinute: 3, sends: 5, allowed: 3, reason: "账户每分钟 3 封"},
+		{name: "per day", perMinute: 100, perDay: 2, sends: 4, allowed: 2, reason: "账户每天 2 封"},
+		{name: "per recipient", perRecipientHour: 1, sends: 3, allowed: 1, reason: "收件人每小时 1 封"},
+		{name: "all exceeded", perMinute: 1, perDay: 1, perRecipientHour: 1, sends: 2, allowed: 1, reason: "账户每分钟 1 封、账户每天 1 封、收件人每小时 1 封"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestRateLimiter(account, tt.perMinute, tt.perDay, tt.perRecipientHour, "to@example.com")
+			allowed := 0
+			for i := 0; i < tt.sends; i++ {
+				wait, reason := l.Reserve(account, "To@Example.com")
+				if wait <= 0 {
+					allowed++
+					continue
+				}
+				if reason != tt.reason {
+					t.Errorf("reason = %q, want %q", reason, tt.reason)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d of %d sends, want %d", allowed, tt.sends, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRateLimiterReservePrunesRecipients(t *testing.T) {
+	account := &models.Account{ID: 1}
+	l := newTestRateLimiter(account, 0, 0, 10, "idle@example.com", "busy@example.com")
+	l.recipientHour["busy@example.com"].take(time.Now())
+	l.lastPrune = time.Now().Add(-2 * recipientPruneInterval)
+
+	if wait, _ := l.Reserve(account, "busy@example.com"); wait > 0 {
+		t.Fatalf("Reserve waited %v, want 0", wait)
+	}
+	if _, ok := l.recipientHour["idle@example.com"]; ok {
+		t.Error("full recipient bucket was not pruned")
+	}
+	if _, ok := l.recipientHour["busy@example.com"]; !ok {
+		t.Error("recipient bucket in use was pruned")
+	}
+}
+
+func TestQuotaUsageFillRemaining(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		usage QuotaUsage
+		quota quota
+		want  *int
+	}{
+		{name: "unlimited", usage: QuotaUsage{Limit: 0, Sent: 5}},
+		{name: "from sent count", usage: QuotaUsage{Limit: 10, Sent: 4}, want: intPtr(6)},
+		{name: "sent over limit", usage: QuotaUsage{Limit: 10, Sent: 12}, want: intPtr(0)},
+		{name: "from window", usage: QuotaUsage{Limit: 3, Sent: 0}, quota: newSlidingWindow(3, accountDayWindow, []time.Time{now}), want: intPtr(2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.usage.fillRemaining(tt.quota, now)
+			switch {
+			case tt.want == nil && tt.usage.Remaining != nil:
+				t.Errorf("remaining = %d, want nil", *tt.usage.Remaining)
+			case tt.want != nil && (tt.usage.Remaining == nil || *tt.usage.Remaining != *tt.want):
+				t.Errorf("remaining = %v, want %d", tt.usage.Remaining, *tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int { return &n }
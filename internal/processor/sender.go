@@ -0,0 +1,200 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"gmail-forwarding/internal/config"
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+)
+
+// senderPolicy 发件人授权策略
+//
+// 全局可信发件人列表和规则可信发件人列表都非空时，发件人需同时命中两者；列表为空表示不限制。
+// 开启发件人认证时，还要求邮件头中可信服务器添加的 Authentication-Results 通过 SPF/DKIM/DMARC 检查。
+type senderPolicy struct {
+	global      []models.TrustedSender
+	byRule      map[uint][]models.TrustedSender
+	requireAuth bool
+	authServID  string
+}
+
+// loadSenderPolicy 预加载所有启用的可信发件人
+func (ep *EmailProcessor) loadSenderPolicy() (*senderPolicy, error) {
+	db := database.GetDB()
+	var senders []models.TrustedSender
+
+	if err := db.Where("active = ?", true).Find(&senders).Error; err != nil {
+		return nil, fmt.Errorf("加载可信发件人失败: %w", err)
+	}
+
+	policy := &senderPolicy{
+		byRule:      make(map[uint][]models.TrustedSender),
+		requireAuth: config.GlobalConfig.RequireSenderAuth,
+		authServID:  config.GlobalConfig.AuthServID,
+	}
+	for _, sender := range senders {
+		if sender.RuleID == nil {
+			policy.global = append(policy.global, sender)
+		} else {
+			policy.byRule[*sender.RuleID] = append(policy.byRule[*sender.RuleID], sender)
+		}
+	}
+	return policy, nil
+}
+
+// check 检查发件人是否有权通过指定规则触发转发，无权时返回原因
+func (p *senderPolicy) check(email *gmail.Email, rule *models.ForwardingRule) error {
+	sender := strings.ToLower(strings.TrimSpace(email.FromAddress))
+	if sender == "" {
+		return fmt.Errorf("无法识别发件人地址")
+	}
+
+	if p.requireAuth || rule.RequireSenderAuth {
+		if err := verifyAuthenticationResults(email, sender, p.authServID); err != nil {
+			return fmt.Errorf("发件人 %s 认证失败: %w", sender, err)
+		}
+	}
+
+	if len(p.global) > 0 && !matchAnySender(p.global, sender) {
+		return fmt.Errorf("发件人 %s 不在全局可信发件人列表中", sender)
+	}
+
+	if senders := p.byRule[rule.ID]; len(senders) > 0 && !matchAnySender(senders, sender) {
+		return fmt.Errorf("发件人 %s 不在规则 '%s' 的可信发件人列表中", sender, rule.Keyword)
+	}
+
+	return nil
+}
+
+// matchAnySender 检查发件人是否命中任一可信发件人
+func matchAnySender(senders []models.TrustedSender, address string) bool {
+	for i := range senders {
+		if senders[i].Matches(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// authResult Authentication-Results 中的单项认证结果
+type authResult struct {
+	method string
+	result string
+	props  map[string]string
+}
+
+// verifyAuthenticationResults 检查可信服务器添加的 Authentication-Results 是否通过认证
+//
+// 只信任 authserv-id 与配置一致的第一条（最靠近顶部的）记录，发件人可以伪造其余记录。
+// 满足以下任一条件视为通过：
+//   - dmarc=pass 且 header.from 与发件人域名一致（缺少 header.from 时无法确认 DMARC 针对的是发件人域名，不视为通过）
+//   - dkim=pass 且签名域名与发件人域名对齐
+//   - spf=pass 且 MAIL FROM 域名与发件人域名对齐
+func verifyAuthenticationResults(email *gmail.Email, sender, authServID string) error {
+	var results []authResult
+	found := false
+	for _, value := range email.Header.Values("Authentication-Results") {
+		id, parsed := parseAuthenticationResults(value)
+		if strings.EqualFold(id, authServID) {
+			results = parsed
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("缺少 %s 添加的 Authentication-Results 头", authServID)
+	}
+
+	domain := addressDomain(sender)
+	for _, r := range results {
+		if r.result != "pass" {
+			continue
+		}
+		switch r.method {
+		case "dmarc":
+			if from := r.props["header.from"]; from != "" && from == domain {
+				return nil
+			}
+		case "dkim":
+			signer := r.props["header.d"]
+			if signer == "" {
+				signer = r.props["header.i"]
+			}
+			if domainsAligned(addressDomain(signer), domain) {
+				return nil
+			}
+		case "spf":
+			if domainsAligned(addressDomain(r.props["smtp.mailfrom"]), domain) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("SPF/DKIM/DMARC 均未通过")
+}
+
+// parseAuthenticationResults 解析 Authentication-Results 头（RFC 8601），返回 authserv-id 和各项结果
+func parseAuthenticationResults(value string) (string, []authResult) {
+	segments := strings.Split(stripComments(value), ";")
+	fields := strings.Fields(segments[0])
+	if len(fields) == 0 {
+		return "", nil
+	}
+	authServID := fields[0]
+
+	var results []authResult
+	for _, segment := range segments[1:] {
+		tokens := strings.Fields(segment)
+		if len(tokens) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(tokens[0], "=")
+		if !ok {
+			continue
+		}
+		r := authResult{
+			method: strings.ToLower(method),
+			result: strings.ToLower(result),
+			props:  make(map[string]string),
+		}
+		for _, token := range tokens[1:] {
+			if k, v, ok := strings.Cut(token, "="); ok {
+				r.props[strings.ToLower(k)] = strings.ToLower(strings.Trim(v, `"`))
+			}
+		}
+		results = append(results, r)
+	}
+	return authServID, results
+}
+
+// stripComments 去掉头部值中括号内的注释
+func stripComments(value string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range value {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// addressDomain 获取地址的域名部分，参数本身是域名时原样返回
+func addressDomain(value string) string {
+	return strings.ToLower(value[strings.LastIndex(value, "@")+1:])
+}
+
+// domainsAligned 宽松对齐：认证域名与发件人域名相同，或发件人域名是认证域名的子域名
+func domainsAligned(authDomain, senderDomain string) bool {
+	if !strings.Contains(authDomain, ".") || senderDomain == "" {
+		return false
+	}
+	return authDomain == senderDomain || strings.HasSuffix(senderDomain, "."+authDomain)
+}
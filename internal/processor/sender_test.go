@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gmail-forwarding/internal/gmail"
+)
+
+func TestParseAuthenticationResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		id      string
+		results []authResult
+	}{
+		{
+			name:  "gmail style",
+			value: `mx.google.com; dkim=pass header.i=@example.com header.s=s1 header.b=abc; spf=pass (google.com: domain of a@example.com designates 1.2.3.4 as permitted sender) smtp.mailfrom=a@example.com; dmarc=pass (p=REJECT sp=REJECT dis=NONE) header.from=example.com`,
+			id:    "mx.google.com",
+			results: []authResult{
+				{method: "dkim", result: "pass", props: map[string]string{"header.i": "@example.com", "header.s": "s1", "header.b": "abc"}},
+				{method: "spf", result: "pass", props: map[string]string{"smtp.mailfrom": "a@example.com"}},
+				{method: "dmarc", result: "pass", props: map[string]string{"header.from": "example.com"}},
+			},
+		},
+		{
+			name:  "version, case and quoted values",
+			value: `MX.Example.NET 1; SPF=SoftFail smtp.mailfrom="Bounce@Example.COM"`,
+			id:    "MX.Example.NET",
+			results: []authResult{
+				{method: "spf", result: "softfail", props: map[string]string{"smtp.mailfrom": "bounce@example.com"}},
+			},
+		},
+		{
+			name:  "no results",
+			value: "mx.google.com; none",
+			id:    "mx.google.com",
+		},
+		{
+			name:  "empty",
+			value: "  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, results := parseAuthenticationResults(tt.value)
+			if id != tt.id {
+				t.Errorf("authserv-id = %q, want %q", id, tt.id)
+			}
+			if !reflect.DeepEqual(results, tt.results) {
+				t.Errorf("results = %+v, want %+v", results, tt.results)
+			}
+		})
+	}
+}
+
+func TestVerifyAuthenticationResults(t *testing.T) {
+	const authServID = "mx.google.com"
+
+	tests := []struct {
+		name    string
+		sender  string
+		headers []string // 按从上到下的顺序
+		wantErr string
+	}{
+		{
+			name:    "dmarc pass",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dmarc=pass header.from=example.com"},
+		},
+		{
+			name:    "dmarc pass without header.from",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dmarc=pass"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "dmarc pass for another domain",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dmarc=pass header.from=attacker.com"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "dkim pass aligned",
+			sender:  "alice@mail.example.com",
+			headers: []string{"mx.google.com; dkim=pass header.d=example.com"},
+		},
+		{
+			name:    "dkim pass with header.i",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dkim=pass header.i=@example.com"},
+		},
+		{
+			name:    "dkim pass not aligned",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dkim=pass header.d=attacker.com"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "dkim pass for parent suffix only",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dkim=pass header.d=com"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "dkim pass for lookalike domain",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; dkim=pass header.d=badexample.com"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "spf pass aligned",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; spf=pass smtp.mailfrom=bounce@example.com"},
+		},
+		{
+			name:    "spf softfail",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; spf=softfail smtp.mailfrom=alice@example.com"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "spf pass not aligned",
+			sender:  "alice@example.com",
+			headers: []string{"mx.google.com; spf=pass smtp.mailfrom=bounce@mailer.net"},
+			wantErr: "均未通过",
+		},
+		{
+			name:    "missing header",
+			sender:  "alice@example.com",
+			wantErr: "缺少",
+		},
+		{
+			name:    "foreign authserv-id ignored",
+			sender:  "alice@example.com",
+			headers: []string{"evil.example.net; dmarc=pass header.from=example.com"},
+			wantErr: "缺少",
+		},
+		{
+			name:   "foreign header before trusted one ignored",
+			sender: "alice@example.com",
+			headers: []string{
+				"evil.example.net; dmarc=pass header.from=example.com",
+				"mx.google.com; spf=fail smtp.mailfrom=alice@example.com",
+			},
+			wantErr: "均未通过",
+		},
+		{
+			name:   "only the topmost trusted header counts",
+			sender: "alice@example.com",
+			headers: []string{
+				"mx.google.com; spf=softfail smtp.mailfrom=alice@example.com",
+				"mx.google.com; dmarc=pass header.from=example.com",
+			},
+			wantErr: "均未通过",
+		},
+		{
+			name:   "trusted header after a foreign one",
+			sender: "alice@example.com",
+			headers: []string{
+				"evil.example.net; spf=fail",
+				"MX.Google.com; dkim=pass header.d=example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &gmail.Email{Header: make(textproto.MIMEHeader)}
+			for _, value := range tt.headers {
+				email.Header.Add("Authentication-Results", value)
+			}
+
+			err := verifyAuthenticationResults(email, tt.sender, authServID)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("verifyAuthenticationResults = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("verifyAuthenticationResults = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+
+	"gmail-forwarding/internal/database"
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultMailbox 默认处理的邮箱文件夹
+const DefaultMailbox = "INBOX"
+
+// loadSyncState 加载邮箱的增量同步状态，不存在时返回空状态（触发全量同步）
+func (ep *EmailProcessor) loadSyncState(mailbox string) (gmail.SyncState, error) {
+	db := database.GetDB()
+	var record models.MailboxSyncState
+
+	err := db.Where("account = ? AND mailbox = ?", ep.imapClient.Username(), mailbox).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return gmail.SyncState{}, nil
+	}
+	if err != nil {
+		return gmail.SyncState{}, fmt.Errorf("加载邮箱同步状态失败: %w", err)
+	}
+
+	state := gmail.SyncState{
+		UIDValidity: record.UIDValidity,
+		LastUID:     record.LastUID,
+	}
+	if record.LastSyncedAt != nil {
+		state.LastSyncedAt = *record.LastSyncedAt
+	}
+	return state, nil
+}
+
+// saveSyncState 保存邮箱的增量同步状态
+func (ep *EmailProcessor) saveSyncState(mailbox string, state gmail.SyncState) error {
+	db := database.GetDB()
+	record := models.MailboxSyncState{
+		Account: ep.imapClient.Username(),
+		Mailbox: mailbox,
+	}
+
+	err := db.Where("account = ? AND mailbox = ?", record.Account, record.Mailbox).FirstOrInit(&record).Error
+	if err != nil {
+		return fmt.Errorf("加载邮箱同步状态失败: %w", err)
+	}
+
+	syncedAt := state.LastSyncedAt
+	record.UIDValidity = state.UIDValidity
+	record.LastUID = state.LastUID
+	record.LastSyncedAt = &syncedAt
+
+	if err := db.Save(&record).Error; err != nil {
+		return fmt.Errorf("保存邮箱同步状态失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/processor"
+)
+
+const (
+	idleInitialBackoff = time.Second
+	idleMaxBackoff     = 5 * time.Minute
+	// idleStableDuration 连接保持超过该时长视为稳定，重置重连退避时间
+	idleStableDuration = time.Minute
+)
+
+// IdleWatcher 基于 IMAP IDLE 的新邮件监听器
+type IdleWatcher struct {
+	ctx             context.Context
+	newClient       func() (*gmail.IMAPClient, error)
+	emailProcessor  *processor.EmailProcessor
+	mailbox         string
+	restartInterval time.Duration
+
+	trigger chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewIdleWatcher 创建新的 IDLE 监听器，newClient 用于创建独立的长连接客户端，
+// ctx 取消时关闭 IDLE 连接并中断正在进行的邮件处理
+func NewIdleWatcher(ctx context.Context, newClient func() (*gmail.IMAPClient, error), emailProcessor *processor.EmailProcessor, mailbox string, restartInterval time.Duration) *IdleWatcher {
+	return &IdleWatcher{
+		ctx:             ctx,
+		newClient:       newClient,
+		emailProcessor:  emailProcessor,
+		mailbox:         mailbox,
+		restartInterval: restartInterval,
+		trigger:         make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+	}
+}
+
+// Start 启动监听
+func (w *IdleWatcher) Start() {
+	w.wg.Add(2)
+	go w.watchLoop()
+	go w.processLoop()
+	log.Printf("IDLE 监听已启动: %s", w.mailbox)
+}
+
+// Stop 停止监听并等待退出
+func (w *IdleWatcher) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+	log.Printf("IDLE 监听已停止: %s", w.mailbox)
+}
+
+// notify 触发一次邮件处理，处理中收到的多次通知会合并为一次
+func (w *IdleWatcher) notify() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// processLoop 串行执行由 IDLE 通知触发的邮件处理
+func (w *IdleWatcher) processLoop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.trigger:
+			log.Println("IDLE 收到新邮件通知，开始处理邮件...")
+			if err := w.emailProcessor.ProcessEmails(w.ctx); err != nil {
+				log.Printf("IDLE 触发处理邮件失败: %v", err)
+			}
+		}
+	}
+}
+
+// watchLoop 保持 IDLE 连接，断开后按指数退避重连
+func (w *IdleWatcher) watchLoop() {
+	defer w.wg.Done()
+	backoff := idleInitialBackoff
+
+	for {
+		started := time.Now()
+		err := w.watchOnce()
+
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		if time.Since(started) > idleStableDuration {
+			backoff = idleInitialBackoff
+		}
+		log.Printf("IDLE 连接断开: %v，%v 后重连", err, backoff)
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > idleMaxBackoff {
+			backoff = idleMaxBackoff
+		}
+	}
+}
+
+// watchOnce 建立一次 IDLE 连接并阻塞直到断开或停止
+func (w *IdleWatcher) watchOnce() error {
+	imapClient, err := w.newClient()
+	if err != nil {
+		return err
+	}
+	if err := imapClient.Connect(w.ctx); err != nil {
+		return err
+	}
+	defer imapClient.Disconnect()
+
+	// 重连后立即处理一次，补上断线期间到达的邮件
+	w.notify()
+
+	return imapClient.Idle(w.mailbox, w.restartInterval, w.stop, w.notify)
+}
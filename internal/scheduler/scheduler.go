@@ -1,58 +1,131 @@
 package scheduler
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
+	"sync"
 	"time"
 
+	"gmail-forwarding/internal/config"
 	"gmail-forwarding/internal/gmail"
+	"gmail-forwarding/internal/models"
 	"gmail-forwarding/internal/processor"
 
 	"github.com/robfig/cron/v3"
 )
 
-// Scheduler 定时任务调度器
+// Scheduler 定时任务调度器，为每个启用的邮箱账户运行独立的邮件处理器
 type Scheduler struct {
-	cron           *cron.Cron
+	cron    *cron.Cron
+	ctx     context.Context // 邮件处理使用的 context，服务关闭时取消
+	mu      sync.Mutex
+	workers []*accountWorker
+}
+
+// accountWorker 单个账户的处理器、定时任务和 IDLE 监听
+type accountWorker struct {
+	account        models.Account
 	emailProcessor *processor.EmailProcessor
+	entryID        cron.EntryID
+	idleWatchers   []*IdleWatcher // 每个文件夹一个 IDLE 长连接
+
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup // 正在进行的定时处理和启动时处理
 }
 
-// NewScheduler 创建新的调度器
-func NewScheduler() *Scheduler {
-	// 获取Gmail配置
-	gmailUser := os.Getenv("GMAIL_USER")
-	gmailPassword := os.Getenv("GMAIL_APP_PASSWORD")
+// process 执行一次邮件处理，worker 已停止时跳过
+func (w *accountWorker) process(ctx context.Context, trigger string) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.running.Add(1)
+	w.mu.Unlock()
+	defer w.running.Done()
 
-	if gmailUser == "" || gmailPassword == "" {
-		log.Fatal("Gmail配置不完整，请检查GMAIL_USER和GMAIL_APP_PASSWORD环境变量")
+	log.Printf("%s，开始处理邮件 [%s]...", trigger, w.account.Email)
+	if err := w.emailProcessor.ProcessEmails(ctx); err != nil {
+		log.Printf("%s处理邮件失败 [%s]: %v", trigger, w.account.Email, err)
 	}
+}
 
-	// 创建客户端
-	imapClient := gmail.NewIMAPClient(gmailUser, gmailPassword)
-	smtpClient := gmail.NewSMTPClient(gmailUser, gmailPassword)
+// stop 停止 IDLE 监听并等待正在进行的邮件处理完成，调用前需先移除定时任务
+//
+// 重新加载账户时新的处理器使用不同的锁，必须等旧的处理结束后再启动，避免同一账户被并发处理。
+func (w *accountWorker) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
 
-	// 创建处理器
-	emailProcessor := processor.NewEmailProcessor(imapClient, smtpClient)
+	for _, watcher := range w.idleWatchers {
+		watcher.Stop()
+	}
+	w.running.Wait()
+}
 
+// NewScheduler 创建新的调度器
+func NewScheduler() *Scheduler {
 	// 创建cron实例，支持秒级调度
-	c := cron.New(cron.WithSeconds())
-
 	return &Scheduler{
-		cron:           c,
-		emailProcessor: emailProcessor,
+		cron: cron.New(cron.WithSeconds()),
 	}
 }
 
-// Start 启动定时任务
-func (s *Scheduler) Start() {
-	// 获取检查间隔配置，默认5分钟
-	interval := os.Getenv("CHECK_INTERVAL")
-	if interval == "" {
-		interval = "5m"
+// Start 启动定时任务，ctx 取消时中断正在进行的邮件处理
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+
+	// 启动调度器
+	s.cron.Start()
+	log.Println("邮件处理定时任务已启动")
+
+	s.Reload()
+}
+
+// Reload 重新加载邮箱账户：停止所有账户的定时任务和 IDLE 监听，再按当前启用的账户重新启动
+func (s *Scheduler) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopWorkers()
+
+	accounts, err := processor.LoadAccounts()
+	if err != nil {
+		log.Printf("加载邮箱账户失败: %v", err)
+		return
+	}
+	if len(accounts) == 0 {
+		log.Println("没有启用的邮箱账户，请配置GMAIL_USER或通过API添加账户")
+		return
+	}
+
+	for i := range accounts {
+		worker, err := s.startWorker(accounts[i])
+		if err != nil {
+			log.Printf("启动账户 %s 失败: %v", accounts[i].Email, err)
+			continue
+		}
+		s.workers = append(s.workers, worker)
+	}
+	log.Printf("已启动 %d 个邮箱账户的邮件处理", len(s.workers))
+}
+
+// startWorker 为账户创建处理器并启动定时任务和 IDLE 监听
+func (s *Scheduler) startWorker(account models.Account) (*accountWorker, error) {
+	emailProcessor, err := processor.NewAccountProcessor(&account)
+	if err != nil {
+		return nil, err
 	}
+	w := &accountWorker{account: account, emailProcessor: emailProcessor}
 
-	// 解析间隔时间
+	// 解析间隔时间，账户未设置时使用全局配置，默认5分钟
+	interval := account.CheckInterval
+	if interval == "" {
+		interval = config.GlobalConfig.CheckInterval
+	}
 	duration, err := time.ParseDuration(interval)
 	if err != nil {
 		log.Printf("无效的检查间隔配置 %s，使用默认值5分钟", interval)
@@ -61,59 +134,88 @@ func (s *Scheduler) Start() {
 
 	// 构建cron表达式（每N分钟执行一次）
 	cronExpr := buildCronExpression(duration)
-	log.Printf("设置邮件检查间隔: %s (cron: %s)", duration, cronExpr)
+	log.Printf("账户 %s 邮件检查间隔: %s (cron: %s)", account.Email, duration, cronExpr)
 
 	// 添加定时任务
-	_, err = s.cron.AddFunc(cronExpr, func() {
-		log.Println("定时任务触发，开始处理邮件...")
-		if err := s.emailProcessor.ProcessEmails(); err != nil {
-			log.Printf("定时处理邮件失败: %v", err)
-		}
+	w.entryID, err = s.cron.AddFunc(cronExpr, func() {
+		w.process(s.ctx, "定时任务触发")
 	})
-
 	if err != nil {
-		log.Fatalf("添加定时任务失败: %v", err)
+		return nil, fmt.Errorf("添加定时任务失败: %w", err)
 	}
 
-	// 启动调度器
-	s.cron.Start()
-	log.Println("邮件处理定时任务已启动")
+	// IDLE 模式下为每个文件夹使用独立的长连接监听新邮件，定时任务作为兜底
+	syncMode := account.SyncMode
+	if syncMode == "" {
+		syncMode = config.GlobalConfig.SyncMode
+	}
+	if syncMode == config.SyncModeIdle {
+		restartInterval, err := time.ParseDuration(config.GlobalConfig.IdleRestartInterval)
+		if err != nil {
+			log.Printf("无效的IDLE重发间隔配置 %s，使用默认值 %s", config.GlobalConfig.IdleRestartInterval, gmail.DefaultIdleRestartInterval)
+			restartInterval = gmail.DefaultIdleRestartInterval
+		}
+		newClient := func() (*gmail.IMAPClient, error) {
+			imapClient, _, err := processor.NewAccountClients(&account)
+			return imapClient, err
+		}
+		for _, mailbox := range processor.AccountMailboxes(&account) {
+			watcher := NewIdleWatcher(s.ctx, newClient, emailProcessor, mailbox, restartInterval)
 
-	// 立即执行一次
-	go func() {
-		log.Println("启动时执行一次邮件处理...")
-		if err := s.emailProcessor.ProcessEmails(); err != nil {
-			log.Printf("启动时处理邮件失败: %v", err)
+			// 启动 IDLE 监听（连接建立后会立即处理一次）
+			watcher.Start()
+			w.idleWatchers = append(w.idleWatchers, watcher)
 		}
-	}()
+		return w, nil
+	}
+
+	// 立即执行一次
+	go w.process(s.ctx, "启动时执行一次")
+	return w, nil
+}
+
+// stopWorkers 停止所有账户的定时任务和 IDLE 监听，并等待正在进行的邮件处理完成
+func (s *Scheduler) stopWorkers() {
+	for _, w := range s.workers {
+		s.cron.Remove(w.entryID)
+	}
+	for _, w := range s.workers {
+		w.stop()
+	}
+	s.workers = nil
 }
 
 // Stop 停止定时任务
 func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.cron != nil {
 		s.cron.Stop()
 		log.Println("定时任务已停止")
 	}
+
+	s.stopWorkers()
 }
 
 // buildCronExpression 根据时间间隔构建cron表达式
 func buildCronExpression(duration time.Duration) string {
 	minutes := int(duration.Minutes())
-	
+
 	if minutes < 1 {
 		// 小于1分钟的，按秒处理
 		seconds := int(duration.Seconds())
 		return formatSeconds(seconds)
 	}
-	
+
 	if minutes == 1 {
 		return "0 * * * * *" // 每分钟执行
 	}
-	
+
 	if minutes < 60 {
 		return formatMinutes(minutes)
 	}
-	
+
 	// 大于等于60分钟的，按小时处理
 	hours := minutes / 60
 	return formatHours(hours)
@@ -127,7 +229,7 @@ func formatSeconds(seconds int) string {
 	return "0 * * * * *" // 默认每分钟
 }
 
-// formatMinutes 格式化分钟级cron表达式  
+// formatMinutes 格式化分钟级cron表达式
 func formatMinutes(minutes int) string {
 	return "0 0/" + fmt.Sprintf("%d", minutes) + " * * * *"
 }
@@ -135,4 +237,4 @@ func formatMinutes(minutes int) string {
 // formatHours 格式化小时级cron表达式
 func formatHours(hours int) string {
 	return "0 0 0/" + fmt.Sprintf("%d", hours) + " * * *"
-}
\ No newline at end of file
+}